@@ -0,0 +1,109 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog/log"
+)
+
+// busIDVec adds the pci_bus_id label to the series of a vector with a uuid
+// label, see -collect.pci-bus-id-label. The vector itself is written with the
+// usual labels; the PCI bus ID of the device is looked up in its static
+// information when the series are collected.
+type busIDVec struct {
+	vec
+	c      *Collector
+	desc   *prometheus.Desc
+	m      metric
+	labels []string
+}
+
+// newBusIDVec wraps v, the vector declared by m, in a busIDVec.
+func newBusIDVec(c *Collector, v vec, m metric) *busIDVec {
+	labels := append(append([]string(nil), m.labels...), "pci_bus_id")
+	return &busIDVec{
+		vec:    v,
+		c:      c,
+		desc:   prometheus.NewDesc(prometheus.BuildFQName(namespace, "", m.name), m.help, labels, nil),
+		m:      m,
+		labels: labels,
+	}
+}
+
+// needsBusID reports whether the series of a vector with the given labels get
+// the pci_bus_id label with -collect.pci-bus-id-label.
+func needsBusID(labels []string) bool {
+	var uuid bool
+	for _, l := range labels {
+		switch l {
+		case "uuid":
+			uuid = true
+		case "pci_bus_id":
+			return false
+		}
+	}
+	return uuid
+}
+
+func (v *busIDVec) Describe(ch chan<- *prometheus.Desc) {
+	ch <- v.desc
+}
+
+// Collect collects the series of the wrapped vector with the PCI bus ID of
+// their device added. The caller must hold the lock of the collector.
+func (v *busIDVec) Collect(ch chan<- prometheus.Metric) {
+	series := make(chan prometheus.Metric)
+	go func() {
+		v.vec.Collect(series)
+		close(series)
+	}()
+
+	for s := range series {
+		var pb dto.Metric
+		if err := s.Write(&pb); err != nil {
+			log.Debug().
+				Err(err).
+				Str("metric", v.m.name).
+				Msg("Cannot read series to add the pci_bus_id label")
+			continue
+		}
+
+		values := make(map[string]string, len(v.labels))
+		for _, l := range pb.GetLabel() {
+			values[l.GetName()] = l.GetValue()
+		}
+		if info, ok := v.c.static[values["uuid"]]; ok {
+			values["pci_bus_id"] = info.pciBusID
+		}
+		labelValues := make([]string, len(v.labels))
+		for i, l := range v.labels {
+			labelValues[i] = values[l]
+		}
+
+		var (
+			m   prometheus.Metric
+			err error
+		)
+		switch {
+		case v.m.gauge != nil:
+			m, err = prometheus.NewConstMetric(v.desc, prometheus.GaugeValue, pb.GetGauge().GetValue(), labelValues...)
+		case v.m.counter != nil:
+			m, err = prometheus.NewConstMetric(v.desc, prometheus.CounterValue, pb.GetCounter().GetValue(), labelValues...)
+		case v.m.histogram != nil:
+			h := pb.GetHistogram()
+			buckets := make(map[float64]uint64, len(h.GetBucket()))
+			for _, b := range h.GetBucket() {
+				buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+			m, err = prometheus.NewConstHistogram(v.desc, h.GetSampleCount(), h.GetSampleSum(), buckets, labelValues...)
+		}
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Str("metric", v.m.name).
+				Msg("Cannot add the pci_bus_id label")
+			continue
+		}
+		ch <- m
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestBusIDLabel(t *testing.T) {
+	defer func(v bool) { *pciBusIDLabel = v }(*pciBusIDLabel)
+
+	for _, enabled := range []bool{false, true} {
+		*pciBusIDLabel = enabled
+		c := NewCollector()
+		c.nvml = &fakeNVML{
+			devices: []*fakeDevice{
+				{uuid: "GPU-0", name: "Tesla T4", pciBusID: "00000000:3B:00.0", power: 70000},
+				{minor: 1, uuid: "GPU-1", name: "Tesla T4", power: 30000},
+			},
+		}
+
+		// The pedantic registry checks the series against their descriptions.
+		reg := prometheus.NewPedanticRegistry()
+		reg.MustRegister(c)
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatalf("collect.pci-bus-id-label=%v: %v", enabled, err)
+		}
+
+		got := make(map[string]string)
+		for _, f := range families {
+			if f.GetName() != "nvidia_gpu_power_usage_watts" {
+				continue
+			}
+			for _, m := range f.GetMetric() {
+				labels := seriesLabels(m)
+				busID, ok := labels["pci_bus_id"]
+				if ok != enabled {
+					t.Errorf("collect.pci-bus-id-label=%v: series has labels %v", enabled, labels)
+				}
+				got[labels["uuid"]] = busID
+			}
+		}
+		if !enabled {
+			continue
+		}
+		want := map[string]string{"GPU-0": "00000000:3B:00.0", "GPU-1": ""}
+		if len(got) != len(want) || got["GPU-0"] != want["GPU-0"] || got["GPU-1"] != want["GPU-1"] {
+			t.Errorf("pci_bus_id by uuid = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestNeedsBusID(t *testing.T) {
+	tests := []struct {
+		labels []string
+		want   bool
+	}{
+		{labels, true},
+		{processLabels, true},
+		{infoLabels, false},
+		{unitLabels, false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := needsBusID(tt.labels); got != tt.want {
+			t.Errorf("needsBusID(%v) = %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xofym/gonvml"
+)
+
+// namedClock is a clock type with its clock label value.
+type namedClock struct {
+	clock     string
+	clockType gonvml.ClockType
+}
+
+// offsetClocks are the clocks that can be offset.
+var offsetClocks = []namedClock{
+	{"graphics", gonvml.ClockGraphics},
+	{"memory", gonvml.ClockMem},
+}
+
+// boostClocks are the clocks whose maximum customer boost clock is exported.
+var boostClocks = []namedClock{
+	{"graphics", gonvml.ClockGraphics},
+	{"sm", gonvml.ClockSM},
+	{"memory", gonvml.ClockMem},
+}
+
+// collectClockOffsets reads the clock offsets of the device for every
+// performance state it supports. Only newer drivers export the function, which
+// is probed once. The caller must hold the lock.
+func (c *Collector) collectClockOffsets(dev nvmlDevice, i int, minor, uuid, name string) {
+	if c.clockOffsetsUnsupported {
+		return
+	}
+
+	pstates, err := dev.SupportedPerformanceStates()
+	if err != nil {
+		c.queryFailed(err, i, "SupportedPerformanceStates")
+		return
+	}
+
+	for _, pstate := range pstates {
+		pstateLabel := "P" + strconv.FormatUint(uint64(pstate), 10)
+		for _, clock := range offsetClocks {
+			offset, err := dev.ClockOffsets(clock.clockType, pstate)
+			if isNVMLError(err, gonvml.ErrorFunctionNotFound) {
+				log.Info().
+					Msg("Clock offsets are not supported by the driver, not collecting them")
+				c.clockOffsetsUnsupported = true
+				return
+			}
+			if err != nil {
+				c.queryFailed(err, i, "ClockOffsets")
+				continue
+			}
+			c.clockOffset.WithLabelValues(minor, uuid, name, clock.clock, pstateLabel).Set(float64(offset.Offset) * 1e6)
+		}
+	}
+}
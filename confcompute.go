@@ -0,0 +1,47 @@
+package main
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/xofym/gonvml"
+)
+
+// collectConfCompute reads the confidential computing state of the system.
+// Drivers without confidential computing don't have the functions or don't
+// support them, in which case the collector stays disabled from then on. The
+// caller must hold the lock.
+func (c *Collector) collectConfCompute() {
+	if c.confComputeUnsupported {
+		return
+	}
+
+	state, err := c.nvml.SystemConfComputeState()
+	if isNVMLError(err, gonvml.ErrorFunctionNotFound) || isNVMLError(err, gonvml.ErrorNotSupported) {
+		log.Info().
+			Err(err).
+			Msg("Confidential computing is not supported, not collecting confidential computing metrics")
+		c.confComputeUnsupported = true
+		return
+	}
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Cannot get SystemConfComputeState")
+		c.countError(err)
+		return
+	}
+	c.confComputeEnabled.WithLabelValues().Set(boolToFloat64(state.CCFeature))
+	c.confComputeDevTools.WithLabelValues().Set(boolToFloat64(state.DevToolsMode))
+
+	if !state.CCFeature {
+		return
+	}
+	ready, err := c.nvml.SystemConfComputeGpusReadyState()
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Cannot get SystemConfComputeGpusReadyState")
+		c.countError(err)
+		return
+	}
+	c.confComputeReady.WithLabelValues().Set(boolToFloat64(ready))
+}
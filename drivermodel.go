@@ -0,0 +1,15 @@
+package main
+
+// driverModelNames maps nvmlDriverModel_t values to label values.
+var driverModelNames = map[uint]string{
+	0: "wddm",
+	1: "tcc",
+	2: "mcdm",
+}
+
+func driverModelName(model uint) string {
+	if name, ok := driverModelNames[model]; ok {
+		return name
+	}
+	return "unknown"
+}
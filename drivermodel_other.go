@@ -0,0 +1,7 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+// collectDriverModel does nothing, driver models only exist on Windows.
+func (c *Collector) collectDriverModel(dev nvmlDevice, i int, minor, uuid, name string) {}
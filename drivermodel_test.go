@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestDriverModelName(t *testing.T) {
+	tests := []struct {
+		model uint
+		want  string
+	}{
+		{0, "wddm"},
+		{1, "tcc"},
+		{2, "mcdm"},
+		{3, "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := driverModelName(tt.model); got != tt.want {
+			t.Errorf("driverModelName(%d) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
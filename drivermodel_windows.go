@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package main
+
+// collectDriverModel exports the current and pending driver model of a
+// device. The caller must hold the lock.
+func (c *Collector) collectDriverModel(dev nvmlDevice, i int, minor, uuid, name string) {
+	current, pending, err := dev.DriverModel()
+	if err != nil {
+		c.queryFailed(err, i, "DriverModel")
+		return
+	}
+	c.driverModelCurrent.WithLabelValues(minor, uuid, name, driverModelName(current)).Set(1)
+	c.driverModelPending.WithLabelValues(minor, uuid, name, driverModelName(pending)).Set(1)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/xofym/gonvml"
+)
+
+// nvmlErrorCodes maps NVML return codes to the values of the code label, which
+// follow the names of the return codes.
+var nvmlErrorCodes = map[gonvml.Return]string{
+	gonvml.ErrorUninitialized:           "uninitialized",
+	gonvml.ErrorInvalidArgument:         "invalid_argument",
+	gonvml.ErrorNotSupported:            "not_supported",
+	gonvml.ErrorNoPermission:            "no_permission",
+	gonvml.ErrorAlreadyInitialized:      "already_initialized",
+	gonvml.ErrorNotFound:                "not_found",
+	gonvml.ErrorInsufficientSize:        "insufficient_size",
+	gonvml.ErrorInsufficientPower:       "insufficient_power",
+	gonvml.ErrorDriverNotLoaded:         "driver_not_loaded",
+	gonvml.ErrorTimeout:                 "timeout",
+	gonvml.ErrorIRQIssue:                "irq_issue",
+	gonvml.ErrorLibraryNotFound:         "library_not_found",
+	gonvml.ErrorFunctionNotFound:        "function_not_found",
+	gonvml.ErrorCorruptedInforom:        "corrupted_inforom",
+	gonvml.ErrorGPUIsLost:               "gpu_is_lost",
+	gonvml.ErrorResetRequired:           "reset_required",
+	gonvml.ErrorOperatingSystem:         "operating_system",
+	gonvml.ErrorLibRMVersionMismatch:    "lib_rm_version_mismatch",
+	gonvml.ErrorInUse:                   "in_use",
+	gonvml.ErrorMemory:                  "memory",
+	gonvml.ErrorNoData:                  "no_data",
+	gonvml.ErrorVgpuEccNotSupported:     "vgpu_ecc_not_supported",
+	gonvml.ErrorInsufficientResources:   "insufficient_resources",
+	gonvml.ErrorFreqNotSupported:        "freq_not_supported",
+	gonvml.ErrorArgumentVersionMismatch: "argument_version_mismatch",
+	gonvml.ErrorDeprecated:              "deprecated",
+	gonvml.ErrorNotReady:                "not_ready",
+	gonvml.ErrorGPUNotFound:             "gpu_not_found",
+	gonvml.ErrorInvalidState:            "invalid_state",
+	gonvml.ErrorResetTypeNotSupported:   "reset_type_not_supported",
+	gonvml.ErrorUnknown:                 "unknown",
+}
+
+// nvmlReturn returns the NVML return code carried by err. ok is false if err
+// isn't the error of an NVML function.
+func nvmlReturn(err error) (ret gonvml.Return, ok bool) {
+	var nvmlErr *gonvml.Error
+	if !errors.As(err, &nvmlErr) {
+		return 0, false
+	}
+	return nvmlErr.Return, true
+}
+
+// isNVMLError reports whether err is the error of an NVML function that
+// returned ret.
+func isNVMLError(err error, ret gonvml.Return) bool {
+	got, ok := nvmlReturn(err)
+	return ok && got == ret
+}
+
+// nvmlErrorCode returns the value of the code label for err. Errors that don't
+// carry a known NVML return code are reported as "other".
+func nvmlErrorCode(err error) string {
+	if ret, ok := nvmlReturn(err); ok {
+		if code, ok := nvmlErrorCodes[ret]; ok {
+			return code
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/xofym/gonvml"
+)
+
+func TestNVMLErrorCode(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"not supported", errNotSupported, "not_supported"},
+		{"gpu lost", &gonvml.Error{Return: gonvml.ErrorGPUIsLost, Message: "GPU is lost"}, "gpu_is_lost"},
+		{"wrapped", fmt.Errorf("cannot read device: %w", errTimeout), "timeout"},
+		// Only the return code counts, not the message.
+		{"message only", errors.New("nvml: Not Supported"), "other"},
+		{"unknown return code", &gonvml.Error{Return: 1000, Message: "Unknown Error"}, "other"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nvmlErrorCode(tt.err); got != tt.want {
+				t.Errorf("nvmlErrorCode(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsNVMLError(t *testing.T) {
+	if !isNVMLError(errNotSupported, gonvml.ErrorNotSupported) {
+		t.Error("Not Supported error not recognized")
+	}
+	if isNVMLError(errNotSupported, gonvml.ErrorNotFound) {
+		t.Error("Not Supported error recognized as Not Found")
+	}
+	if isNVMLError(nil, gonvml.ErrorNotSupported) {
+		t.Error("nil recognized as an NVML error")
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xofym/gonvml"
+)
+
+const (
+	// eventTypes are the NVML events the exporter listens for.
+	eventTypes = gonvml.EventTypeXidCriticalError | gonvml.EventTypeDoubleBitEccError
+
+	// eventWaitTimeout bounds how long a single wait for events blocks, so
+	// that the event loop notices when it has to stop.
+	eventWaitTimeout = 5 * time.Second
+)
+
+// eventRetryInterval is how long to wait before registering for events again
+// after the event set failed. It is a variable so that tests can shorten it.
+var eventRetryInterval = 30 * time.Second
+
+// watchEvents listens for NVML events on all devices and counts them until
+// stop is closed. done is closed once the event set has been freed.
+//
+// Events are delivered through an event set that the devices are registered
+// with. When waiting on the set fails, e.g. because a device fell off the bus,
+// the set is freed and the devices are registered with a new one.
+func (c *Collector) watchEvents(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	for {
+		set, err := c.registerEvents()
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Msg("Cannot register for events")
+		} else {
+			waitErr := c.waitEvents(set, stop)
+			if err := set.Free(); err != nil {
+				log.Warn().
+					Err(err).
+					Msg("Cannot free event set")
+			}
+			if waitErr == nil {
+				return
+			}
+			log.Warn().
+				Err(waitErr).
+				Msg("Cannot wait for events")
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(eventRetryInterval):
+		}
+	}
+}
+
+// registerEvents creates an event set and registers every device that
+// supports any of the event types with it.
+func (c *Collector) registerEvents() (nvmlEventSet, error) {
+	set, err := c.nvml.NewEventSet()
+	if err != nil {
+		return set, err
+	}
+
+	numDevices, err := c.nvml.DeviceCount()
+	if err != nil {
+		set.Free()
+		return set, err
+	}
+
+	for i := 0; i < int(numDevices); i++ {
+		dev, err := c.nvml.DeviceHandleByIndex(uint(i))
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Int("device_index", i).
+				Msg("Cannot get DeviceHandleByIndex")
+			continue
+		}
+
+		supported, err := dev.SupportedEventTypes()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Msg("Cannot get SupportedEventTypes")
+			continue
+		}
+		if supported&eventTypes == 0 {
+			continue
+		}
+
+		if err := dev.RegisterEvents(supported&eventTypes, set); err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Msg("Cannot RegisterEvents")
+		}
+	}
+	return set, nil
+}
+
+// waitEvents handles the events delivered to set until stop is closed, in
+// which case it returns nil, or until waiting fails.
+func (c *Collector) waitEvents(set nvmlEventSet, stop <-chan struct{}) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+
+		event, err := set.Wait(eventWaitTimeout)
+		if isNVMLError(err, gonvml.ErrorTimeout) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.handleEvent(event)
+	}
+}
+
+func (c *Collector) handleEvent(event nvmlEvent) {
+	minor, uuid, name, query, err := deviceLabelValues(event.Device)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Uint64("event_type", event.EventType).
+			Msg("Cannot get device " + query + " for event")
+		return
+	}
+
+	switch event.EventType {
+	case gonvml.EventTypeXidCriticalError:
+		xid := strconv.FormatUint(event.EventData, 10)
+		log.Warn().
+			Str("uuid", uuid).
+			Str("xid", xid).
+			Msg("XID error")
+		c.xidErrors.WithLabelValues(minor, uuid, name, xid).Inc()
+	case gonvml.EventTypeDoubleBitEccError:
+		log.Warn().
+			Str("uuid", uuid).
+			Msg("Double bit ECC error")
+		c.eccDBEEvents.WithLabelValues(minor, uuid, name).Inc()
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/xofym/gonvml"
+)
+
+// startWatchEvents runs watchEvents on a collector using lib and returns the
+// channels to stop it and to wait for it.
+func startWatchEvents(lib *fakeNVML) (c *Collector, stop chan struct{}, done chan struct{}) {
+	c = NewCollector()
+	c.nvml = lib
+	stop = make(chan struct{})
+	done = make(chan struct{})
+	go c.watchEvents(stop, done)
+	return c, stop, done
+}
+
+func newEventsNVML() *fakeNVML {
+	return &fakeNVML{
+		devices: []*fakeDevice{
+			{
+				minor:           0,
+				uuid:            "GPU-0",
+				name:            "Tesla T4",
+				supportedEvents: gonvml.EventTypeSingleBitEccError | gonvml.EventTypeDoubleBitEccError | gonvml.EventTypeXidCriticalError,
+			},
+			{
+				minor: 1,
+				uuid:  "GPU-1",
+				name:  "Tesla T4",
+			},
+		},
+		eventSets: make(chan *fakeEventSet, 1),
+	}
+}
+
+func TestWatchEventsCountsEvents(t *testing.T) {
+	lib := newEventsNVML()
+	c, stop, done := startWatchEvents(lib)
+
+	set := <-lib.eventSets
+	dev := lib.devices[0]
+	for _, xid := range []uint64{79, 79, 48} {
+		set.waits <- fakeWait{event: nvmlEvent{Device: dev, EventType: gonvml.EventTypeXidCriticalError, EventData: xid}}
+	}
+	set.waits <- fakeWait{event: nvmlEvent{Device: dev, EventType: gonvml.EventTypeDoubleBitEccError}}
+	close(stop)
+	<-done
+
+	if got := testutil.ToFloat64(c.xidErrors.WithLabelValues("0", "GPU-0", "Tesla T4", "79")); got != 2 {
+		t.Errorf("xid 79 errors = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(c.xidErrors.WithLabelValues("0", "GPU-0", "Tesla T4", "48")); got != 1 {
+		t.Errorf("xid 48 errors = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.eccDBEEvents.WithLabelValues("0", "GPU-0", "Tesla T4")); got != 1 {
+		t.Errorf("double bit ECC events = %v, want 1", got)
+	}
+	if len(lib.devices[0].registered) != 1 || lib.devices[0].registered[0] != set {
+		t.Errorf("device 0 registered with %v, want the event set", lib.devices[0].registered)
+	}
+	if len(lib.devices[1].registered) != 0 {
+		t.Errorf("device 1 without event support registered with %v", lib.devices[1].registered)
+	}
+}
+
+func TestWatchEventsWithoutMinorNumber(t *testing.T) {
+	lib := newEventsNVML()
+	lib.devices[0].noMinor = true
+	c, stop, done := startWatchEvents(lib)
+
+	set := <-lib.eventSets
+	set.waits <- fakeWait{event: nvmlEvent{Device: lib.devices[0], EventType: gonvml.EventTypeXidCriticalError, EventData: 79}}
+	close(stop)
+	<-done
+
+	if got := testutil.ToFloat64(c.xidErrors.WithLabelValues("", "GPU-0", "Tesla T4", "79")); got != 1 {
+		t.Errorf("xid 79 errors = %v, want 1", got)
+	}
+}
+
+func TestWatchEventsRegistersAgainAfterFailedWait(t *testing.T) {
+	defer func(d time.Duration) { eventRetryInterval = d }(eventRetryInterval)
+	eventRetryInterval = time.Millisecond
+
+	lib := newEventsNVML()
+	c, stop, done := startWatchEvents(lib)
+
+	first := <-lib.eventSets
+	first.waits <- fakeWait{err: &gonvml.Error{Return: gonvml.ErrorGPUIsLost, Message: "GPU is lost"}}
+
+	second := <-lib.eventSets
+	if !first.freed {
+		t.Error("failed event set not freed")
+	}
+	dev := lib.devices[0]
+	second.waits <- fakeWait{event: nvmlEvent{Device: dev, EventType: gonvml.EventTypeXidCriticalError, EventData: 79}}
+	close(stop)
+	<-done
+
+	if len(dev.registered) != 2 || dev.registered[1] != second {
+		t.Errorf("device registered with %v, want both event sets", dev.registered)
+	}
+	if got := testutil.ToFloat64(c.xidErrors.WithLabelValues("0", "GPU-0", "Tesla T4", "79")); got != 1 {
+		t.Errorf("xid 79 errors = %v, want 1", got)
+	}
+}
+
+func TestWatchEventsStops(t *testing.T) {
+	lib := newEventsNVML()
+	_, stop, done := startWatchEvents(lib)
+
+	set := <-lib.eventSets
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("watchEvents did not stop")
+	}
+	if !set.freed {
+		t.Error("event set not freed")
+	}
+	select {
+	case <-lib.eventSets:
+		t.Error("event set created after stop")
+	default:
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xofym/gonvml"
+)
+
+// fieldIDs are the IDs of the fields set with -collector.fields.
+var fieldIDs []uint
+
+// parseFieldIDs parses the comma separated list of field IDs of
+// -collector.fields.
+func parseFieldIDs(s string) ([]uint, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var ids []uint
+	for _, field := range strings.Split(s, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field ID %q: %v", field, err)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// collectFields reads the fields set with -collector.fields. Fields that the
+// device doesn't support are skipped.
+func (c *Collector) collectFields(dev nvmlDevice, i int, minor, uuid, name string) {
+	values, err := dev.FieldValues(fieldIDs)
+	if err != nil {
+		c.queryFailed(err, i, "FieldValues")
+		return
+	}
+
+	for _, v := range values {
+		id := strconv.FormatUint(uint64(v.FieldID), 10)
+		if v.Err != nil {
+			log.Debug().
+				Err(v.Err).
+				Int("device_index", i).
+				Str("field_id", id).
+				Msg("Cannot get field value")
+			c.countError(v.Err)
+			continue
+		}
+
+		value, err := fieldValueFloat64(v)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Int("device_index", i).
+				Str("field_id", id).
+				Msg("Cannot convert field value")
+			continue
+		}
+		c.fieldValue.WithLabelValues(minor, uuid, name, id, gonvml.FieldName(v.FieldID)).Set(value)
+	}
+}
+
+// fieldValueFloat64 converts the value of a field, which NVML returns as a
+// union of the type given by its value type, to a float64.
+func fieldValueFloat64(v gonvml.FieldValue) (float64, error) {
+	switch v.ValueType {
+	case gonvml.ValueTypeDouble:
+		return math.Float64frombits(binary.LittleEndian.Uint64(v.Value[:])), nil
+	case gonvml.ValueTypeUnsignedInt:
+		return float64(binary.LittleEndian.Uint32(v.Value[:])), nil
+	case gonvml.ValueTypeUnsignedLong, gonvml.ValueTypeUnsignedLongLong:
+		return float64(binary.LittleEndian.Uint64(v.Value[:])), nil
+	case gonvml.ValueTypeSignedLongLong:
+		return float64(int64(binary.LittleEndian.Uint64(v.Value[:]))), nil
+	case gonvml.ValueTypeSignedInt:
+		return float64(int32(binary.LittleEndian.Uint32(v.Value[:]))), nil
+	}
+	return 0, fmt.Errorf("unknown value type %d of field %d", v.ValueType, v.FieldID)
+}
+
+// NVML_GPU_RECOVERY_ACTION_* values of nvmlDeviceGpuRecoveryAction_t.
+const (
+	recoveryActionNone          = 0
+	recoveryActionGPUReset      = 1
+	recoveryActionNodeReboot    = 2
+	recoveryActionDrainP2P      = 3
+	recoveryActionDrainAndReset = 4
+)
+
+// recoveryActionNames maps nvmlDeviceGpuRecoveryAction_t values to label values.
+var recoveryActionNames = map[int]string{
+	recoveryActionNone:          "none",
+	recoveryActionGPUReset:      "gpu_reset",
+	recoveryActionNodeReboot:    "node_reboot",
+	recoveryActionDrainP2P:      "drain_p2p",
+	recoveryActionDrainAndReset: "drain_and_reset",
+}
+
+func recoveryActionName(action int) string {
+	if name, ok := recoveryActionNames[action]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// collectRecoveryAction reads the action the driver recommends to recover the
+// device, e.g. after an XID error, and whether that involves a reset.
+//
+// Drivers that don't report the recovery action reject the field as not
+// supported or as an invalid argument. There, a reset is required while a
+// remapping of memory rows is pending, which is the most common reason.
+func (c *Collector) collectRecoveryAction(dev nvmlDevice, i int, minor, uuid, name string) {
+	values, err := dev.FieldValues([]uint{gonvml.FieldDevGetGpuRecoveryAction})
+	if err == nil && len(values) != 1 {
+		err = fmt.Errorf("got %d values for 1 field", len(values))
+	}
+	if err == nil {
+		err = values[0].Err
+	}
+	if isNVMLError(err, gonvml.ErrorNotSupported) || isNVMLError(err, gonvml.ErrorInvalidArgument) {
+		log.Debug().
+			Err(err).
+			Int("device_index", i).
+			Msg("Cannot get GpuRecoveryAction, falling back to RemappedRows")
+
+		_, _, pending, _, err := dev.RemappedRows()
+		if err != nil {
+			c.queryFailed(err, i, "RemappedRows")
+			return
+		}
+		c.resetRequired.WithLabelValues(minor, uuid, name).Set(boolToFloat64(pending))
+		return
+	}
+	if err != nil {
+		c.queryFailed(err, i, "GpuRecoveryAction")
+		return
+	}
+
+	value, err := fieldValueFloat64(values[0])
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Int("device_index", i).
+			Msg("Cannot convert GpuRecoveryAction")
+		return
+	}
+
+	action := int(value)
+	c.recoveryAction.WithLabelValues(minor, uuid, name, recoveryActionName(action)).Set(1)
+	resetRequired := action == recoveryActionGPUReset || action == recoveryActionNodeReboot || action == recoveryActionDrainAndReset
+	c.resetRequired.WithLabelValues(minor, uuid, name).Set(boolToFloat64(resetRequired))
+}
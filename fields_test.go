@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/xofym/gonvml"
+)
+
+// fieldValue returns a field value of the given type holding the
+// little-endian bytes of bits.
+func fieldValue(valueType gonvml.ValueType, bits uint64) gonvml.FieldValue {
+	v := gonvml.FieldValue{ValueType: valueType}
+	binary.LittleEndian.PutUint64(v.Value[:], bits)
+	return v
+}
+
+func TestFieldValueFloat64(t *testing.T) {
+	minusTwo := int32(-2)
+	minusThree := int64(-3)
+
+	tests := []struct {
+		name    string
+		value   gonvml.FieldValue
+		want    float64
+		wantErr bool
+	}{
+		{"double", fieldValue(gonvml.ValueTypeDouble, math.Float64bits(1.5)), 1.5, false},
+		{"negative double", fieldValue(gonvml.ValueTypeDouble, math.Float64bits(-0.25)), -0.25, false},
+		{"unsigned int", fieldValue(gonvml.ValueTypeUnsignedInt, 4000000000), 4000000000, false},
+		// Only the lower 32 bits belong to an unsigned int.
+		{"unsigned int with garbage", fieldValue(gonvml.ValueTypeUnsignedInt, 1<<32|7), 7, false},
+		{"unsigned long", fieldValue(gonvml.ValueTypeUnsignedLong, 1<<40), 1 << 40, false},
+		{"unsigned long long", fieldValue(gonvml.ValueTypeUnsignedLongLong, 1<<63), 1 << 63, false},
+		{"signed long long", fieldValue(gonvml.ValueTypeSignedLongLong, 3), 3, false},
+		{"negative signed long long", fieldValue(gonvml.ValueTypeSignedLongLong, uint64(minusThree)), -3, false},
+		{"signed int", fieldValue(gonvml.ValueTypeSignedInt, 2), 2, false},
+		{"negative signed int", fieldValue(gonvml.ValueTypeSignedInt, uint64(uint32(minusTwo))), -2, false},
+		{"unknown type", fieldValue(gonvml.ValueType(42), 1), 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fieldValueFloat64(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fieldValueFloat64() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("fieldValueFloat64() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFieldIDs(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []uint
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"1", []uint{1}, false},
+		{"1, 2,271", []uint{1, 2, 271}, false},
+		{"1,,2", nil, true},
+		{"-1", nil, true},
+		{"ecc", nil, true},
+		{"4294967296", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFieldIDs(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFieldIDs(%q) error = %v, want error %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFieldIDs(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestFieldNames(t *testing.T) {
+	for id, want := range map[uint]string{
+		1:   "dev_ecc_current",
+		6:   "dev_ecc_dbe_agg_total",
+		7:   "",
+		29:  "dev_retired_sbe",
+		30:  "dev_retired_dbe",
+		31:  "dev_retired_pending",
+		230: "",
+	} {
+		if got := gonvml.FieldName(id); got != want {
+			t.Errorf("FieldName(%d) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestCollectFields(t *testing.T) {
+	defer func(ids []uint) { fieldIDs = ids }(fieldIDs)
+	fieldIDs = []uint{1, 3, 9, 500}
+
+	failed := fieldValue(gonvml.ValueTypeUnsignedLongLong, 5)
+	failed.Err = errNotSupported
+	dev := &fakeDevice{
+		uuid: "GPU-0",
+		name: "Tesla T4",
+		fields: map[uint]gonvml.FieldValue{
+			1:   fieldValue(gonvml.ValueTypeUnsignedInt, 1),
+			3:   fieldValue(gonvml.ValueTypeUnsignedLongLong, 12),
+			9:   failed,
+			500: fieldValue(gonvml.ValueType(42), 1),
+		},
+	}
+	c := NewCollector()
+	c.collectFields(dev, 0, "0", "GPU-0", "Tesla T4")
+
+	series := collectSeries(c.fieldValue)
+	if len(series) != 2 {
+		t.Fatalf("got %d fields, want 2", len(series))
+	}
+	if got := testutil.ToFloat64(c.fieldValue.WithLabelValues("0", "GPU-0", "Tesla T4", "1", "dev_ecc_current")); got != 1 {
+		t.Errorf("field 1 = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(c.fieldValue.WithLabelValues("0", "GPU-0", "Tesla T4", "3", "dev_ecc_sbe_vol_total")); got != 12 {
+		t.Errorf("field 3 = %v, want 12", got)
+	}
+}
+
+// fieldValuesDevice returns values from FieldValues, whatever the fields.
+type fieldValuesDevice struct {
+	fakeDevice
+	values []gonvml.FieldValue
+}
+
+func (d *fieldValuesDevice) FieldValues(fieldIDs []uint) ([]gonvml.FieldValue, error) {
+	return d.values, nil
+}
+
+func TestCollectRecoveryAction(t *testing.T) {
+	tests := []struct {
+		name          string
+		values        []gonvml.FieldValue
+		action        string
+		resetRequired float64
+	}{
+		{
+			name:   "none",
+			values: []gonvml.FieldValue{fieldValue(gonvml.ValueTypeUnsignedInt, recoveryActionNone)},
+			action: "none",
+		},
+		{
+			name:          "node reboot",
+			values:        []gonvml.FieldValue{fieldValue(gonvml.ValueTypeUnsignedInt, recoveryActionNodeReboot)},
+			action:        "node_reboot",
+			resetRequired: 1,
+		},
+		{
+			name: "no values",
+		},
+		{
+			name: "too many values",
+			values: []gonvml.FieldValue{
+				fieldValue(gonvml.ValueTypeUnsignedInt, recoveryActionGPUReset),
+				fieldValue(gonvml.ValueTypeUnsignedInt, recoveryActionGPUReset),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev := &fieldValuesDevice{fakeDevice: fakeDevice{uuid: "GPU-0"}, values: tt.values}
+			c := NewCollector()
+			c.collectRecoveryAction(dev, 0, "0", "GPU-0", "Tesla T4")
+
+			series := collectSeries(c.recoveryAction)
+			if tt.action == "" {
+				if len(series) != 0 || len(collectSeries(c.resetRequired)) != 0 {
+					t.Errorf("recovery action exported for %d values", len(tt.values))
+				}
+				return
+			}
+			if len(series) != 1 || seriesLabels(series[0])["action"] != tt.action {
+				t.Errorf("recovery action series = %v, want action %s", series, tt.action)
+			}
+			if got := testutil.ToFloat64(c.resetRequired); got != tt.resetRequired {
+				t.Errorf("reset required = %v, want %v", got, tt.resetRequired)
+			}
+		})
+	}
+}
+
+func TestCollectRecoveryActionFieldID(t *testing.T) {
+	// NVML_FI_DEV_GET_GPU_RECOVERY_ACTION in nvml.h.
+	const recoveryActionField = 230
+
+	dev := &fakeDevice{
+		uuid: "GPU-0",
+		fields: map[uint]gonvml.FieldValue{
+			recoveryActionField: fieldValue(gonvml.ValueTypeUnsignedInt, recoveryActionGPUReset),
+		},
+	}
+	c := NewCollector()
+	c.collectRecoveryAction(dev, 0, "0", "GPU-0", "Tesla T4")
+
+	if want := [][]uint{{recoveryActionField}}; !reflect.DeepEqual(dev.fieldRequests, want) {
+		t.Errorf("requested fields %v, want %v", dev.fieldRequests, want)
+	}
+	if got := testutil.ToFloat64(c.recoveryAction.WithLabelValues("0", "GPU-0", "Tesla T4", "gpu_reset")); got != 1 {
+		t.Errorf("recovery action gpu_reset = %v, want 1", got)
+	}
+}
@@ -4,6 +4,10 @@ go 1.13
 
 require (
 	github.com/prometheus/client_golang v1.2.1
+	github.com/prometheus/client_model v0.0.0-20190812154241-14fe0d1b01d4
+	github.com/prometheus/common v0.7.0
 	github.com/rs/zerolog v1.16.0
 	github.com/xofym/gonvml v0.0.0-20191028123445-9eb1200e279b
 )
+
+replace github.com/xofym/gonvml => ./gonvml
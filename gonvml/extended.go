@@ -0,0 +1,1276 @@
+/*
+Copyright 2017 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gonvml
+
+// #cgo CFLAGS: -Wno-deprecated-declarations
+/*
+#include <stddef.h>
+#include <dlfcn.h>
+
+#include "nvml.h"
+
+// nvmlHandle is defined in bindings.go.
+extern void *nvmlHandle;
+
+// NVML_OPTIONAL declares a pointer to an NVML function and a wrapper that
+// calls it. Unlike the functions in bindings.go, these are not required to
+// initialize NVML: the wrapper returns NVML_ERROR_FUNCTION_NOT_FOUND when the
+// driver is too old to export the function.
+#define NVML_OPTIONAL(name, params, args) \
+  static nvmlReturn_t (*name##Func) params; \
+  static nvmlReturn_t name##_dl params { \
+    if (name##Func == NULL) { \
+      return NVML_ERROR_FUNCTION_NOT_FOUND; \
+    } \
+    return name##Func args; \
+  }
+
+NVML_OPTIONAL(nvmlSystemGetNVMLVersion, (char *version, unsigned int length), (version, length))
+NVML_OPTIONAL(nvmlSystemGetCudaDriverVersion, (int *version), (version))
+NVML_OPTIONAL(nvmlGetExcludedDeviceCount, (unsigned int *count), (count))
+NVML_OPTIONAL(nvmlGetExcludedDeviceInfoByIndex, (unsigned int index, nvmlExcludedDeviceInfo_t *info), (index, info))
+NVML_OPTIONAL(nvmlSystemGetConfComputeState, (nvmlConfComputeSystemState_t *state), (state))
+NVML_OPTIONAL(nvmlSystemGetConfComputeGpusReadyState, (unsigned int *ready), (ready))
+
+NVML_OPTIONAL(nvmlUnitGetCount, (unsigned int *count), (count))
+NVML_OPTIONAL(nvmlUnitGetHandleByIndex, (unsigned int index, nvmlUnit_t *unit), (index, unit))
+NVML_OPTIONAL(nvmlUnitGetUnitInfo, (nvmlUnit_t unit, nvmlUnitInfo_t *info), (unit, info))
+NVML_OPTIONAL(nvmlUnitGetPsuInfo, (nvmlUnit_t unit, nvmlPSUInfo_t *psu), (unit, psu))
+NVML_OPTIONAL(nvmlUnitGetFanSpeedInfo, (nvmlUnit_t unit, nvmlUnitFanSpeeds_t *fans), (unit, fans))
+NVML_OPTIONAL(nvmlUnitGetTemperature, (nvmlUnit_t unit, unsigned int type, unsigned int *temp), (unit, type, temp))
+
+NVML_OPTIONAL(nvmlEventSetCreate, (nvmlEventSet_t *set), (set))
+NVML_OPTIONAL(nvmlEventSetFree, (nvmlEventSet_t set), (set))
+NVML_OPTIONAL(nvmlEventSetWait, (nvmlEventSet_t set, nvmlEventData_t *data, unsigned int timeoutms), (set, data, timeoutms))
+NVML_OPTIONAL(nvmlDeviceGetSupportedEventTypes, (nvmlDevice_t device, unsigned long long *types), (device, types))
+NVML_OPTIONAL(nvmlDeviceRegisterEvents, (nvmlDevice_t device, unsigned long long types, nvmlEventSet_t set), (device, types, set))
+
+NVML_OPTIONAL(nvmlGpmSampleAlloc, (nvmlGpmSample_t *sample), (sample))
+NVML_OPTIONAL(nvmlGpmSampleFree, (nvmlGpmSample_t sample), (sample))
+NVML_OPTIONAL(nvmlGpmSampleGet, (nvmlDevice_t device, nvmlGpmSample_t sample), (device, sample))
+NVML_OPTIONAL(nvmlGpmQueryDeviceSupport, (nvmlDevice_t device, nvmlGpmSupport_t *support), (device, support))
+NVML_OPTIONAL(nvmlGpmMetricsGet, (nvmlGpmMetricsGet_t *metrics), (metrics))
+
+NVML_OPTIONAL(nvmlDeviceGetFieldValues, (nvmlDevice_t device, int count, nvmlFieldValue_t *values), (device, count, values))
+NVML_OPTIONAL(nvmlDeviceGetSamples, (nvmlDevice_t device, nvmlSamplingType_t type, unsigned long long lastSeen, nvmlValueType_t *valueType, unsigned int *count, nvmlSample_t *samples), (device, type, lastSeen, valueType, count, samples))
+NVML_OPTIONAL(nvmlDeviceGetMultiGpuBoard, (nvmlDevice_t device, unsigned int *multiGpu), (device, multiGpu))
+NVML_OPTIONAL(nvmlDeviceGetBoardId, (nvmlDevice_t device, unsigned int *id), (device, id))
+NVML_OPTIONAL(nvmlDeviceGetSerial, (nvmlDevice_t device, char *serial, unsigned int length), (device, serial, length))
+NVML_OPTIONAL(nvmlDeviceGetVbiosVersion, (nvmlDevice_t device, char *version, unsigned int length), (device, version, length))
+NVML_OPTIONAL(nvmlDeviceGetPciInfo, (nvmlDevice_t device, nvmlPciInfo_t *pci), (device, pci))
+NVML_OPTIONAL(nvmlDeviceGetBoardPartNumber, (nvmlDevice_t device, char *partNumber, unsigned int length), (device, partNumber, length))
+NVML_OPTIONAL(nvmlDeviceGetCudaComputeCapability, (nvmlDevice_t device, int *major, int *minor), (device, major, minor))
+NVML_OPTIONAL(nvmlDeviceGetProcessUtilization, (nvmlDevice_t device, nvmlProcessUtilizationSample_t *samples, unsigned int *count, unsigned long long lastSeen), (device, samples, count, lastSeen))
+NVML_OPTIONAL(nvmlDeviceGetTemperatureThreshold, (nvmlDevice_t device, nvmlTemperatureThresholds_t threshold, unsigned int *temp), (device, threshold, temp))
+NVML_OPTIONAL(nvmlDeviceGetBrand, (nvmlDevice_t device, nvmlBrandType_t *brand), (device, brand))
+NVML_OPTIONAL(nvmlDeviceGetArchitecture, (nvmlDevice_t device, nvmlDeviceArchitecture_t *arch), (device, arch))
+NVML_OPTIONAL(nvmlDeviceGetAttributes, (nvmlDevice_t device, nvmlDeviceAttributes_t *attributes), (device, attributes))
+NVML_OPTIONAL(nvmlDeviceGetNumGpuCores, (nvmlDevice_t device, unsigned int *cores), (device, cores))
+NVML_OPTIONAL(nvmlDeviceGetNvLinkState, (nvmlDevice_t device, unsigned int link, nvmlEnableState_t *active), (device, link, active))
+NVML_OPTIONAL(nvmlDeviceGetNvLinkErrorCounter, (nvmlDevice_t device, unsigned int link, nvmlNvLinkErrorCounter_t counter, unsigned long long *value), (device, link, counter, value))
+NVML_OPTIONAL(nvmlDeviceGetNvLinkRemotePciInfo, (nvmlDevice_t device, unsigned int link, nvmlPciInfo_t *pci), (device, link, pci))
+NVML_OPTIONAL(nvmlDeviceGetNvLinkRemoteDeviceType, (nvmlDevice_t device, unsigned int link, nvmlIntNvLinkDeviceType_t *type), (device, link, type))
+NVML_OPTIONAL(nvmlDeviceGetDisplayActive, (nvmlDevice_t device, nvmlEnableState_t *active), (device, active))
+NVML_OPTIONAL(nvmlDeviceGetDisplayMode, (nvmlDevice_t device, nvmlEnableState_t *mode), (device, mode))
+NVML_OPTIONAL(nvmlDeviceGetVirtualizationMode, (nvmlDevice_t device, nvmlGpuVirtualizationMode_t *mode), (device, mode))
+NVML_OPTIONAL(nvmlDeviceGetHostVgpuMode, (nvmlDevice_t device, nvmlHostVgpuMode_t *mode), (device, mode))
+NVML_OPTIONAL(nvmlDeviceGetPowerManagementLimitConstraints, (nvmlDevice_t device, unsigned int *minLimit, unsigned int *maxLimit), (device, minLimit, maxLimit))
+NVML_OPTIONAL(nvmlDeviceGetPowerManagementDefaultLimit, (nvmlDevice_t device, unsigned int *limit), (device, limit))
+NVML_OPTIONAL(nvmlDeviceGetEnforcedPowerLimit, (nvmlDevice_t device, unsigned int *limit), (device, limit))
+NVML_OPTIONAL(nvmlDeviceGetPerformanceState, (nvmlDevice_t device, nvmlPstates_t *pstate), (device, pstate))
+NVML_OPTIONAL(nvmlDeviceGetPowerSource, (nvmlDevice_t device, nvmlPowerSource_t *source), (device, source))
+NVML_OPTIONAL(nvmlDeviceGetMigMode, (nvmlDevice_t device, unsigned int *current, unsigned int *pending), (device, current, pending))
+NVML_OPTIONAL(nvmlDeviceGetMaxMigDeviceCount, (nvmlDevice_t device, unsigned int *count), (device, count))
+NVML_OPTIONAL(nvmlDeviceGetMigDeviceHandleByIndex, (nvmlDevice_t device, unsigned int index, nvmlDevice_t *mig), (device, index, mig))
+NVML_OPTIONAL(nvmlDeviceGetGpuInstanceId, (nvmlDevice_t device, unsigned int *id), (device, id))
+NVML_OPTIONAL(nvmlDeviceGetComputeInstanceId, (nvmlDevice_t device, unsigned int *id), (device, id))
+NVML_OPTIONAL(nvmlDeviceGetActiveVgpus, (nvmlDevice_t device, unsigned int *count, nvmlVgpuInstance_t *instances), (device, count, instances))
+NVML_OPTIONAL(nvmlVgpuInstanceGetFbUsage, (nvmlVgpuInstance_t instance, unsigned long long *usage), (instance, usage))
+NVML_OPTIONAL(nvmlVgpuInstanceGetVmID, (nvmlVgpuInstance_t instance, char *id, unsigned int size, nvmlVgpuVmIdType_t *type), (instance, id, size, type))
+NVML_OPTIONAL(nvmlDeviceGetGridLicensableFeatures, (nvmlDevice_t device, nvmlGridLicensableFeatures_t *features), (device, features))
+NVML_OPTIONAL(nvmlDeviceGetViolationStatus, (nvmlDevice_t device, nvmlPerfPolicyType_t policy, nvmlViolationTime_t *time), (device, policy, time))
+NVML_OPTIONAL(nvmlDeviceGetMemoryInfo_v2, (nvmlDevice_t device, nvmlMemory_v2_t *memory), (device, memory))
+NVML_OPTIONAL(nvmlDeviceGetGspFirmwareMode, (nvmlDevice_t device, unsigned int *enabled, unsigned int *defaultMode), (device, enabled, defaultMode))
+NVML_OPTIONAL(nvmlDeviceGetRemappedRows, (nvmlDevice_t device, unsigned int *corrRows, unsigned int *uncRows, unsigned int *isPending, unsigned int *failureOccurred), (device, corrRows, uncRows, isPending, failureOccurred))
+NVML_OPTIONAL(nvmlDeviceGetGpuFabricInfo, (nvmlDevice_t device, nvmlGpuFabricInfo_t *info), (device, info))
+NVML_OPTIONAL(nvmlDeviceGetEccMode, (nvmlDevice_t device, nvmlEnableState_t *current, nvmlEnableState_t *pending), (device, current, pending))
+NVML_OPTIONAL(nvmlDeviceGetAccountingMode, (nvmlDevice_t device, nvmlEnableState_t *mode), (device, mode))
+NVML_OPTIONAL(nvmlDeviceGetAccountingPids, (nvmlDevice_t device, unsigned int *count, unsigned int *pids), (device, count, pids))
+NVML_OPTIONAL(nvmlDeviceGetAccountingStats, (nvmlDevice_t device, unsigned int pid, nvmlAccountingStats_t *stats), (device, pid, stats))
+NVML_OPTIONAL(nvmlDeviceGetAccountingBufferSize, (nvmlDevice_t device, unsigned int *size), (device, size))
+NVML_OPTIONAL(nvmlDeviceGetComputeRunningProcesses, (nvmlDevice_t device, unsigned int *count, nvmlProcessInfo_t *infos), (device, count, infos))
+NVML_OPTIONAL(nvmlDeviceGetGraphicsRunningProcesses, (nvmlDevice_t device, unsigned int *count, nvmlProcessInfo_t *infos), (device, count, infos))
+NVML_OPTIONAL(nvmlDeviceGetApplicationsClock, (nvmlDevice_t device, nvmlClockType_t type, unsigned int *clock), (device, type, clock))
+NVML_OPTIONAL(nvmlDeviceGetDefaultApplicationsClock, (nvmlDevice_t device, nvmlClockType_t type, unsigned int *clock), (device, type, clock))
+NVML_OPTIONAL(nvmlDeviceGetMaxCustomerBoostClock, (nvmlDevice_t device, nvmlClockType_t type, unsigned int *clock), (device, type, clock))
+NVML_OPTIONAL(nvmlDeviceGetSupportedPerformanceStates, (nvmlDevice_t device, nvmlPstates_t *pstates, unsigned int size), (device, pstates, size))
+NVML_OPTIONAL(nvmlDeviceGetClockOffsets, (nvmlDevice_t device, nvmlClockOffset_t *info), (device, info))
+NVML_OPTIONAL(nvmlDeviceGetGpuOperationMode, (nvmlDevice_t device, nvmlGpuOperationMode_t *current, nvmlGpuOperationMode_t *pending), (device, current, pending))
+NVML_OPTIONAL(nvmlDeviceGetDriverModel, (nvmlDevice_t device, nvmlDriverModel_t *current, nvmlDriverModel_t *pending), (device, current, pending))
+NVML_OPTIONAL(nvmlDeviceGetNumaNodeId, (nvmlDevice_t device, unsigned int *node), (device, node))
+NVML_OPTIONAL(nvmlDeviceGetIrqNum, (nvmlDevice_t device, unsigned int *irq), (device, irq))
+NVML_OPTIONAL(nvmlDeviceGetEncoderStats, (nvmlDevice_t device, unsigned int *sessionCount, unsigned int *averageFps, unsigned int *averageLatency), (device, sessionCount, averageFps, averageLatency))
+NVML_OPTIONAL(nvmlDeviceGetInforomVersion, (nvmlDevice_t device, nvmlInforomObject_t object, char *version, unsigned int length), (device, object, version, length))
+NVML_OPTIONAL(nvmlDeviceGetInforomImageVersion, (nvmlDevice_t device, char *version, unsigned int length), (device, version, length))
+NVML_OPTIONAL(nvmlDeviceValidateInforom, (nvmlDevice_t device), (device))
+NVML_OPTIONAL(nvmlDeviceGetThermalSettings, (nvmlDevice_t device, unsigned int sensorIndex, nvmlGpuThermalSettings_t *settings), (device, sensorIndex, settings))
+
+// nvmlSym looks up the versioned symbol of an NVML function, falling back to
+// the symbol of the previous version of the function if there is one.
+static void *nvmlSym(const char *symbol, const char *fallback) {
+  void *f = dlsym(nvmlHandle, symbol);
+  if (f == NULL && fallback != NULL) {
+    f = dlsym(nvmlHandle, fallback);
+  }
+  return f;
+}
+
+// Looks up the optional NVML functions. Call this once NVML is initialized.
+static void nvmlLoadOptional_dl(void) {
+  nvmlSystemGetNVMLVersionFunc = nvmlSym("nvmlSystemGetNVMLVersion", NULL);
+  nvmlSystemGetCudaDriverVersionFunc = nvmlSym("nvmlSystemGetCudaDriverVersion_v2", "nvmlSystemGetCudaDriverVersion");
+  nvmlGetExcludedDeviceCountFunc = nvmlSym("nvmlGetExcludedDeviceCount", NULL);
+  nvmlGetExcludedDeviceInfoByIndexFunc = nvmlSym("nvmlGetExcludedDeviceInfoByIndex", NULL);
+  nvmlSystemGetConfComputeStateFunc = nvmlSym("nvmlSystemGetConfComputeState", NULL);
+  nvmlSystemGetConfComputeGpusReadyStateFunc = nvmlSym("nvmlSystemGetConfComputeGpusReadyState", NULL);
+
+  nvmlUnitGetCountFunc = nvmlSym("nvmlUnitGetCount", NULL);
+  nvmlUnitGetHandleByIndexFunc = nvmlSym("nvmlUnitGetHandleByIndex", NULL);
+  nvmlUnitGetUnitInfoFunc = nvmlSym("nvmlUnitGetUnitInfo", NULL);
+  nvmlUnitGetPsuInfoFunc = nvmlSym("nvmlUnitGetPsuInfo", NULL);
+  nvmlUnitGetFanSpeedInfoFunc = nvmlSym("nvmlUnitGetFanSpeedInfo", NULL);
+  nvmlUnitGetTemperatureFunc = nvmlSym("nvmlUnitGetTemperature", NULL);
+
+  nvmlEventSetCreateFunc = nvmlSym("nvmlEventSetCreate", NULL);
+  nvmlEventSetFreeFunc = nvmlSym("nvmlEventSetFree", NULL);
+  nvmlEventSetWaitFunc = nvmlSym("nvmlEventSetWait_v2", NULL);
+  nvmlDeviceGetSupportedEventTypesFunc = nvmlSym("nvmlDeviceGetSupportedEventTypes", NULL);
+  nvmlDeviceRegisterEventsFunc = nvmlSym("nvmlDeviceRegisterEvents", NULL);
+
+  nvmlGpmSampleAllocFunc = nvmlSym("nvmlGpmSampleAlloc", NULL);
+  nvmlGpmSampleFreeFunc = nvmlSym("nvmlGpmSampleFree", NULL);
+  nvmlGpmSampleGetFunc = nvmlSym("nvmlGpmSampleGet", NULL);
+  nvmlGpmQueryDeviceSupportFunc = nvmlSym("nvmlGpmQueryDeviceSupport", NULL);
+  nvmlGpmMetricsGetFunc = nvmlSym("nvmlGpmMetricsGet", NULL);
+
+  nvmlDeviceGetFieldValuesFunc = nvmlSym("nvmlDeviceGetFieldValues", NULL);
+  nvmlDeviceGetSamplesFunc = nvmlSym("nvmlDeviceGetSamples", NULL);
+  nvmlDeviceGetMultiGpuBoardFunc = nvmlSym("nvmlDeviceGetMultiGpuBoard", NULL);
+  nvmlDeviceGetBoardIdFunc = nvmlSym("nvmlDeviceGetBoardId", NULL);
+  nvmlDeviceGetSerialFunc = nvmlSym("nvmlDeviceGetSerial", NULL);
+  nvmlDeviceGetVbiosVersionFunc = nvmlSym("nvmlDeviceGetVbiosVersion", NULL);
+  nvmlDeviceGetPciInfoFunc = nvmlSym("nvmlDeviceGetPciInfo_v3", NULL);
+  nvmlDeviceGetBoardPartNumberFunc = nvmlSym("nvmlDeviceGetBoardPartNumber", NULL);
+  nvmlDeviceGetCudaComputeCapabilityFunc = nvmlSym("nvmlDeviceGetCudaComputeCapability", NULL);
+  nvmlDeviceGetProcessUtilizationFunc = nvmlSym("nvmlDeviceGetProcessUtilization", NULL);
+  nvmlDeviceGetTemperatureThresholdFunc = nvmlSym("nvmlDeviceGetTemperatureThreshold", NULL);
+  nvmlDeviceGetBrandFunc = nvmlSym("nvmlDeviceGetBrand", NULL);
+  nvmlDeviceGetArchitectureFunc = nvmlSym("nvmlDeviceGetArchitecture", NULL);
+  nvmlDeviceGetAttributesFunc = nvmlSym("nvmlDeviceGetAttributes_v2", NULL);
+  nvmlDeviceGetNumGpuCoresFunc = nvmlSym("nvmlDeviceGetNumGpuCores", NULL);
+  nvmlDeviceGetNvLinkStateFunc = nvmlSym("nvmlDeviceGetNvLinkState", NULL);
+  nvmlDeviceGetNvLinkErrorCounterFunc = nvmlSym("nvmlDeviceGetNvLinkErrorCounter", NULL);
+  nvmlDeviceGetNvLinkRemotePciInfoFunc = nvmlSym("nvmlDeviceGetNvLinkRemotePciInfo_v2", NULL);
+  nvmlDeviceGetNvLinkRemoteDeviceTypeFunc = nvmlSym("nvmlDeviceGetNvLinkRemoteDeviceType", NULL);
+  nvmlDeviceGetDisplayActiveFunc = nvmlSym("nvmlDeviceGetDisplayActive", NULL);
+  nvmlDeviceGetDisplayModeFunc = nvmlSym("nvmlDeviceGetDisplayMode", NULL);
+  nvmlDeviceGetVirtualizationModeFunc = nvmlSym("nvmlDeviceGetVirtualizationMode", NULL);
+  nvmlDeviceGetHostVgpuModeFunc = nvmlSym("nvmlDeviceGetHostVgpuMode", NULL);
+  nvmlDeviceGetPowerManagementLimitConstraintsFunc = nvmlSym("nvmlDeviceGetPowerManagementLimitConstraints", NULL);
+  nvmlDeviceGetPowerManagementDefaultLimitFunc = nvmlSym("nvmlDeviceGetPowerManagementDefaultLimit", NULL);
+  nvmlDeviceGetEnforcedPowerLimitFunc = nvmlSym("nvmlDeviceGetEnforcedPowerLimit", NULL);
+  nvmlDeviceGetPerformanceStateFunc = nvmlSym("nvmlDeviceGetPerformanceState", NULL);
+  nvmlDeviceGetPowerSourceFunc = nvmlSym("nvmlDeviceGetPowerSource", NULL);
+  nvmlDeviceGetMigModeFunc = nvmlSym("nvmlDeviceGetMigMode", NULL);
+  nvmlDeviceGetMaxMigDeviceCountFunc = nvmlSym("nvmlDeviceGetMaxMigDeviceCount", NULL);
+  nvmlDeviceGetMigDeviceHandleByIndexFunc = nvmlSym("nvmlDeviceGetMigDeviceHandleByIndex", NULL);
+  nvmlDeviceGetGpuInstanceIdFunc = nvmlSym("nvmlDeviceGetGpuInstanceId", NULL);
+  nvmlDeviceGetComputeInstanceIdFunc = nvmlSym("nvmlDeviceGetComputeInstanceId", NULL);
+  nvmlDeviceGetActiveVgpusFunc = nvmlSym("nvmlDeviceGetActiveVgpus", NULL);
+  nvmlVgpuInstanceGetFbUsageFunc = nvmlSym("nvmlVgpuInstanceGetFbUsage", NULL);
+  nvmlVgpuInstanceGetVmIDFunc = nvmlSym("nvmlVgpuInstanceGetVmID", NULL);
+  nvmlDeviceGetGridLicensableFeaturesFunc = nvmlSym("nvmlDeviceGetGridLicensableFeatures_v4", NULL);
+  nvmlDeviceGetViolationStatusFunc = nvmlSym("nvmlDeviceGetViolationStatus", NULL);
+  nvmlDeviceGetMemoryInfo_v2Func = nvmlSym("nvmlDeviceGetMemoryInfo_v2", NULL);
+  nvmlDeviceGetGspFirmwareModeFunc = nvmlSym("nvmlDeviceGetGspFirmwareMode", NULL);
+  nvmlDeviceGetRemappedRowsFunc = nvmlSym("nvmlDeviceGetRemappedRows", NULL);
+  nvmlDeviceGetGpuFabricInfoFunc = nvmlSym("nvmlDeviceGetGpuFabricInfo", NULL);
+  nvmlDeviceGetEccModeFunc = nvmlSym("nvmlDeviceGetEccMode", NULL);
+  nvmlDeviceGetAccountingModeFunc = nvmlSym("nvmlDeviceGetAccountingMode", NULL);
+  nvmlDeviceGetAccountingPidsFunc = nvmlSym("nvmlDeviceGetAccountingPids", NULL);
+  nvmlDeviceGetAccountingStatsFunc = nvmlSym("nvmlDeviceGetAccountingStats", NULL);
+  nvmlDeviceGetAccountingBufferSizeFunc = nvmlSym("nvmlDeviceGetAccountingBufferSize", NULL);
+  nvmlDeviceGetComputeRunningProcessesFunc = nvmlSym("nvmlDeviceGetComputeRunningProcesses_v3", "nvmlDeviceGetComputeRunningProcesses_v2");
+  nvmlDeviceGetGraphicsRunningProcessesFunc = nvmlSym("nvmlDeviceGetGraphicsRunningProcesses_v3", "nvmlDeviceGetGraphicsRunningProcesses_v2");
+  nvmlDeviceGetApplicationsClockFunc = nvmlSym("nvmlDeviceGetApplicationsClock", NULL);
+  nvmlDeviceGetDefaultApplicationsClockFunc = nvmlSym("nvmlDeviceGetDefaultApplicationsClock", NULL);
+  nvmlDeviceGetMaxCustomerBoostClockFunc = nvmlSym("nvmlDeviceGetMaxCustomerBoostClock", NULL);
+  nvmlDeviceGetSupportedPerformanceStatesFunc = nvmlSym("nvmlDeviceGetSupportedPerformanceStates", NULL);
+  nvmlDeviceGetClockOffsetsFunc = nvmlSym("nvmlDeviceGetClockOffsets", NULL);
+  nvmlDeviceGetGpuOperationModeFunc = nvmlSym("nvmlDeviceGetGpuOperationMode", NULL);
+  nvmlDeviceGetDriverModelFunc = nvmlSym("nvmlDeviceGetDriverModel_v2", "nvmlDeviceGetDriverModel");
+  nvmlDeviceGetNumaNodeIdFunc = nvmlSym("nvmlDeviceGetNumaNodeId", NULL);
+  nvmlDeviceGetIrqNumFunc = nvmlSym("nvmlDeviceGetIrqNum", NULL);
+  nvmlDeviceGetEncoderStatsFunc = nvmlSym("nvmlDeviceGetEncoderStats", NULL);
+  nvmlDeviceGetInforomVersionFunc = nvmlSym("nvmlDeviceGetInforomVersion", NULL);
+  nvmlDeviceGetInforomImageVersionFunc = nvmlSym("nvmlDeviceGetInforomImageVersion", NULL);
+  nvmlDeviceValidateInforomFunc = nvmlSym("nvmlDeviceValidateInforom", NULL);
+  nvmlDeviceGetThermalSettingsFunc = nvmlSym("nvmlDeviceGetThermalSettings", NULL);
+}
+
+// The version fields of the versioned structs are set here because cgo can't
+// evaluate the NVML_STRUCT_VERSION macro.
+
+static nvmlReturn_t nvmlDeviceGetMemoryInfoV2_dl(nvmlDevice_t device, nvmlMemory_v2_t *memory) {
+  memory->version = nvmlMemory_v2;
+  return nvmlDeviceGetMemoryInfo_v2_dl(device, memory);
+}
+
+static nvmlReturn_t nvmlDeviceGetClockOffsetsV1_dl(nvmlDevice_t device, nvmlClockType_t type, nvmlPstates_t pstate, nvmlClockOffset_t *info) {
+  info->version = nvmlClockOffset_v1;
+  info->type = type;
+  info->pstate = pstate;
+  return nvmlDeviceGetClockOffsets_dl(device, info);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+)
+
+const (
+	szNVML     = C.NVML_SYSTEM_NVML_VERSION_BUFFER_SIZE
+	szInforom  = C.NVML_DEVICE_INFOROM_VERSION_BUFFER_SIZE
+	szVMID     = C.NVML_DEVICE_UUID_BUFFER_SIZE
+	szSerial   = C.NVML_DEVICE_SERIAL_BUFFER_SIZE
+	szVbios    = C.NVML_DEVICE_VBIOS_VERSION_BUFFER_SIZE
+	szPart     = C.NVML_DEVICE_PART_NUMBER_BUFFER_SIZE
+	maxPstates = C.NVML_MAX_GPU_PERF_PSTATES
+)
+
+// loadOptional looks up the NVML functions that are only exported by some
+// versions of the driver.
+func loadOptional() {
+	C.nvmlLoadOptional_dl()
+}
+
+// SystemNVMLVersion returns the version of the NVML library.
+func SystemNVMLVersion() (string, error) {
+	var version [szNVML]C.char
+	r := C.nvmlSystemGetNVMLVersion_dl(&version[0], szNVML)
+	return C.GoString(&version[0]), errorString(r)
+}
+
+// SystemCudaDriverVersion returns the version of the CUDA driver, encoded as
+// 1000*major + 10*minor.
+func SystemCudaDriverVersion() (int, error) {
+	var version C.int
+	r := C.nvmlSystemGetCudaDriverVersion_dl(&version)
+	return int(version), errorString(r)
+}
+
+// ExcludedDeviceCount returns the number of devices excluded from use by
+// NVML.
+func ExcludedDeviceCount() (uint, error) {
+	var n C.uint
+	r := C.nvmlGetExcludedDeviceCount_dl(&n)
+	return uint(n), errorString(r)
+}
+
+// ExcludedDeviceInfoByIndex returns the PCI bus id and UUID of an excluded
+// device. The indices range from 0 to ExcludedDeviceCount()-1.
+func ExcludedDeviceInfoByIndex(idx uint) (ExcludedDeviceInfo, error) {
+	var info C.nvmlExcludedDeviceInfo_t
+	r := C.nvmlGetExcludedDeviceInfoByIndex_dl(C.uint(idx), &info)
+	return ExcludedDeviceInfo{
+		PCIBusID: C.GoString(&info.pciInfo.busId[0]),
+		UUID:     C.GoString(&info.uuid[0]),
+	}, errorString(r)
+}
+
+// SystemConfComputeState returns the confidential computing state of the
+// system.
+func SystemConfComputeState() (ConfComputeState, error) {
+	var state C.nvmlConfComputeSystemState_t
+	r := C.nvmlSystemGetConfComputeState_dl(&state)
+	return ConfComputeState{
+		Environment:  uint(state.environment),
+		CCFeature:    state.ccFeature != 0,
+		DevToolsMode: state.devToolsMode != 0,
+	}, errorString(r)
+}
+
+// SystemConfComputeGpusReadyState returns whether the devices accept work in
+// confidential computing mode.
+func SystemConfComputeGpusReadyState() (bool, error) {
+	var ready C.uint
+	r := C.nvmlSystemGetConfComputeGpusReadyState_dl(&ready)
+	return ready != 0, errorString(r)
+}
+
+// Unit is the handle for an S-class unit.
+// This handle is obtained by calling UnitHandleByIndex().
+type Unit struct {
+	unit C.nvmlUnit_t
+}
+
+// UnitCount returns the number of S-class units on the system.
+func UnitCount() (uint, error) {
+	var n C.uint
+	r := C.nvmlUnitGetCount_dl(&n)
+	return uint(n), errorString(r)
+}
+
+// UnitHandleByIndex returns the handle for a particular unit. The indices
+// range from 0 to UnitCount()-1.
+func UnitHandleByIndex(idx uint) (Unit, error) {
+	var unit C.nvmlUnit_t
+	r := C.nvmlUnitGetHandleByIndex_dl(C.uint(idx), &unit)
+	return Unit{unit}, errorString(r)
+}
+
+// Info returns the static information of the unit.
+func (u Unit) Info() (UnitInfo, error) {
+	var info C.nvmlUnitInfo_t
+	r := C.nvmlUnitGetUnitInfo_dl(u.unit, &info)
+	return UnitInfo{
+		Name:            C.GoString(&info.name[0]),
+		ID:              C.GoString(&info.id[0]),
+		Serial:          C.GoString(&info.serial[0]),
+		FirmwareVersion: C.GoString(&info.firmwareVersion[0]),
+	}, errorString(r)
+}
+
+// PsuInfo returns the state of the power supply of the unit.
+func (u Unit) PsuInfo() (PSUInfo, error) {
+	var psu C.nvmlPSUInfo_t
+	r := C.nvmlUnitGetPsuInfo_dl(u.unit, &psu)
+	return PSUInfo{
+		State:   C.GoString(&psu.state[0]),
+		Current: uint(psu.current),
+		Voltage: uint(psu.voltage),
+		Power:   uint(psu.power),
+	}, errorString(r)
+}
+
+// FanSpeedInfo returns the state of the fans of the unit.
+func (u Unit) FanSpeedInfo() ([]UnitFanInfo, error) {
+	var speeds C.nvmlUnitFanSpeeds_t
+	r := C.nvmlUnitGetFanSpeedInfo_dl(u.unit, &speeds)
+	if err := errorString(r); err != nil {
+		return nil, err
+	}
+	n := int(speeds.count)
+	if n > len(speeds.fans) {
+		n = len(speeds.fans)
+	}
+	fans := make([]UnitFanInfo, n)
+	for i := range fans {
+		fans[i] = UnitFanInfo{
+			Speed:  uint(speeds.fans[i].speed),
+			Failed: speeds.fans[i].state == C.NVML_FAN_FAILED,
+		}
+	}
+	return fans, nil
+}
+
+// Temperature returns the temperature of a sensor of the unit in Celsius.
+func (u Unit) Temperature(sensor UnitTemperatureType) (uint, error) {
+	var n C.uint
+	r := C.nvmlUnitGetTemperature_dl(u.unit, C.uint(sensor), &n)
+	return uint(n), errorString(r)
+}
+
+// EventSet is a set of devices and event types to wait for.
+// It is obtained by calling NewEventSet().
+type EventSet struct {
+	set C.nvmlEventSet_t
+}
+
+// NewEventSet creates an empty event set.
+func NewEventSet() (EventSet, error) {
+	var set C.nvmlEventSet_t
+	r := C.nvmlEventSetCreate_dl(&set)
+	return EventSet{set}, errorString(r)
+}
+
+// Free releases the event set.
+func (s EventSet) Free() error {
+	return errorString(C.nvmlEventSetFree_dl(s.set))
+}
+
+// Wait waits up to timeout for an event of one of the registered devices. It
+// returns an "nvml: Timeout" error if no event occurred.
+func (s EventSet) Wait(timeout time.Duration) (EventData, error) {
+	var data C.nvmlEventData_t
+	r := C.nvmlEventSetWait_dl(s.set, &data, C.uint(timeout/time.Millisecond))
+	return EventData{
+		Device:    Device{data.device},
+		EventType: uint64(data.eventType),
+		EventData: uint64(data.eventData),
+	}, errorString(r)
+}
+
+// SupportedEventTypes returns the event types the device can deliver, as a
+// mask of the EventType constants.
+func (d Device) SupportedEventTypes() (uint64, error) {
+	var types C.ulonglong
+	r := C.nvmlDeviceGetSupportedEventTypes_dl(d.dev, &types)
+	return uint64(types), errorString(r)
+}
+
+// RegisterEvents registers the device for the given event types with the set.
+func (d Device) RegisterEvents(eventTypes uint64, set EventSet) error {
+	return errorString(C.nvmlDeviceRegisterEvents_dl(d.dev, C.ulonglong(eventTypes), set.set))
+}
+
+// GpmSample is a buffer holding a GPM sample.
+// It is obtained by calling GpmSampleAlloc().
+type GpmSample struct {
+	sample C.nvmlGpmSample_t
+}
+
+// GpmSampleAlloc allocates a GPM sample buffer.
+func GpmSampleAlloc() (GpmSample, error) {
+	var sample C.nvmlGpmSample_t
+	r := C.nvmlGpmSampleAlloc_dl(&sample)
+	return GpmSample{sample}, errorString(r)
+}
+
+// Free releases the sample buffer.
+func (s GpmSample) Free() error {
+	return errorString(C.nvmlGpmSampleFree_dl(s.sample))
+}
+
+// GpmSampleGet reads a GPM sample of the device into the buffer.
+func (d Device) GpmSampleGet(sample GpmSample) error {
+	return errorString(C.nvmlGpmSampleGet_dl(d.dev, sample.sample))
+}
+
+// GpmQueryDeviceSupport returns whether the device supports GPM.
+func (d Device) GpmQueryDeviceSupport() (bool, error) {
+	var support C.nvmlGpmSupport_t
+	support.version = C.NVML_GPM_SUPPORT_VERSION
+	r := C.nvmlGpmQueryDeviceSupport_dl(d.dev, &support)
+	return support.isSupportedDevice != 0, errorString(r)
+}
+
+// GpmMetricsGet computes the given metrics from two samples of the same
+// device.
+func GpmMetricsGet(sample1, sample2 GpmSample, metricIDs []GpmMetricID) ([]GpmMetric, error) {
+	var get C.nvmlGpmMetricsGet_t
+	if len(metricIDs) > len(get.metrics) {
+		return nil, fmt.Errorf("nvml: at most %d GPM metrics can be read at once", len(get.metrics))
+	}
+	get.version = C.NVML_GPM_METRICS_GET_VERSION
+	get.numMetrics = C.uint(len(metricIDs))
+	get.sample1 = sample1.sample
+	get.sample2 = sample2.sample
+	for i, id := range metricIDs {
+		get.metrics[i].metricId = C.uint(id)
+	}
+	if err := errorString(C.nvmlGpmMetricsGet_dl(&get)); err != nil {
+		return nil, err
+	}
+	metrics := make([]GpmMetric, len(metricIDs))
+	for i, id := range metricIDs {
+		metrics[i] = GpmMetric{
+			MetricID: id,
+			Value:    float64(get.metrics[i].value),
+			Err:      errorString(get.metrics[i].nvmlReturn),
+		}
+	}
+	return metrics, nil
+}
+
+// fieldNames maps the IDs of common fields to their names, which follow the
+// names of the NVML_FI_* constants.
+var fieldNames = map[uint]string{
+	C.NVML_FI_DEV_ECC_CURRENT:       "dev_ecc_current",
+	C.NVML_FI_DEV_ECC_PENDING:       "dev_ecc_pending",
+	C.NVML_FI_DEV_ECC_SBE_VOL_TOTAL: "dev_ecc_sbe_vol_total",
+	C.NVML_FI_DEV_ECC_DBE_VOL_TOTAL: "dev_ecc_dbe_vol_total",
+	C.NVML_FI_DEV_ECC_SBE_AGG_TOTAL: "dev_ecc_sbe_agg_total",
+	C.NVML_FI_DEV_ECC_DBE_AGG_TOTAL: "dev_ecc_dbe_agg_total",
+	C.NVML_FI_DEV_RETIRED_SBE:       "dev_retired_sbe",
+	C.NVML_FI_DEV_RETIRED_DBE:       "dev_retired_dbe",
+	C.NVML_FI_DEV_RETIRED_PENDING:   "dev_retired_pending",
+}
+
+// FieldName returns the name of a common field, e.g. "dev_ecc_current", or
+// an empty string for other fields.
+func FieldName(fieldID uint) string {
+	return fieldNames[fieldID]
+}
+
+// FieldValues reads the given fields of the device. The error of each field
+// is returned in its Err.
+func (d Device) FieldValues(fieldIDs []uint) ([]FieldValue, error) {
+	scopeIDs := make([]uint, len(fieldIDs))
+	return d.fieldValues(fieldIDs, scopeIDs)
+}
+
+// fieldValues reads the given fields, each with its scope, e.g. a link.
+func (d Device) fieldValues(fieldIDs, scopeIDs []uint) ([]FieldValue, error) {
+	if len(fieldIDs) == 0 {
+		return nil, nil
+	}
+	values := make([]C.nvmlFieldValue_t, len(fieldIDs))
+	for i := range values {
+		values[i].fieldId = C.uint(fieldIDs[i])
+		values[i].scopeId = C.uint(scopeIDs[i])
+	}
+	r := C.nvmlDeviceGetFieldValues_dl(d.dev, C.int(len(values)), &values[0])
+	if err := errorString(r); err != nil {
+		return nil, err
+	}
+	fields := make([]FieldValue, len(values))
+	for i, v := range values {
+		fields[i] = FieldValue{
+			FieldID:   uint(v.fieldId),
+			ScopeID:   uint(v.scopeId),
+			Timestamp: int64(v.timestamp),
+			ValueType: ValueType(v.valueType),
+			Value:     *(*[8]byte)(unsafe.Pointer(&v.value)),
+			Err:       errorString(v.nvmlReturn),
+		}
+	}
+	return fields, nil
+}
+
+// fieldUint reads a field holding an unsigned value.
+func (d Device) fieldUint(fieldID, scopeID uint) (uint64, error) {
+	fields, err := d.fieldValues([]uint{fieldID}, []uint{scopeID})
+	if err != nil {
+		return 0, err
+	}
+	if fields[0].Err != nil {
+		return 0, fields[0].Err
+	}
+	return valueUint64(C.nvmlValueType_t(fields[0].ValueType), unsafe.Pointer(&fields[0].Value)), nil
+}
+
+// valueUint64 converts a nvmlValue_t of the given type to an uint64.
+func valueUint64(valueType C.nvmlValueType_t, value unsafe.Pointer) uint64 {
+	switch valueType {
+	case C.NVML_VALUE_TYPE_DOUBLE:
+		return uint64(*(*C.double)(value))
+	case C.NVML_VALUE_TYPE_UNSIGNED_INT:
+		return uint64(*(*C.uint)(value))
+	case C.NVML_VALUE_TYPE_UNSIGNED_LONG:
+		return uint64(*(*C.ulong)(value))
+	case C.NVML_VALUE_TYPE_SIGNED_LONG_LONG:
+		return uint64(*(*C.longlong)(value))
+	case C.NVML_VALUE_TYPE_SIGNED_INT:
+		return uint64(*(*C.int)(value))
+	case C.NVML_VALUE_TYPE_UNSIGNED_SHORT:
+		return uint64(*(*C.ushort)(value))
+	}
+	return uint64(*(*C.ulonglong)(value))
+}
+
+// Samples returns the samples of the given type that the driver recorded
+// after lastSeenTimeStamp, a CPU timestamp in microseconds.
+func (d Device) Samples(samplingType SamplingType, lastSeenTimeStamp uint64) ([]Sample, error) {
+	var valueType C.nvmlValueType_t
+	var n C.uint
+	r := C.nvmlDeviceGetSamples_dl(d.dev, C.nvmlSamplingType_t(samplingType), C.ulonglong(lastSeenTimeStamp), &valueType, &n, nil)
+	if err := errorString(r); err != nil || n == 0 {
+		return nil, err
+	}
+	samples := make([]C.nvmlSample_t, n)
+	r = C.nvmlDeviceGetSamples_dl(d.dev, C.nvmlSamplingType_t(samplingType), C.ulonglong(lastSeenTimeStamp), &valueType, &n, &samples[0])
+	if err := errorString(r); err != nil {
+		return nil, err
+	}
+	result := make([]Sample, n)
+	for i := range result {
+		result[i] = Sample{
+			TimeStamp: uint64(samples[i].timeStamp),
+			Value:     valueUint64(valueType, unsafe.Pointer(&samples[i].sampleValue)),
+		}
+	}
+	return result, nil
+}
+
+// MemoryTemperature returns the temperature of the memory of the device in
+// Celsius. Only devices with a separate memory sensor, e.g. HBM, report it.
+func (d Device) MemoryTemperature() (uint, error) {
+	n, err := d.fieldUint(fieldMemoryTemp, 0)
+	return uint(n), err
+}
+
+// HotspotTemperature returns the hotspot temperature of the device in
+// Celsius. NVML doesn't expose the hotspot sensor yet, so this always returns
+// an "nvml: Not Supported" error.
+func (d Device) HotspotTemperature() (uint, error) {
+	return 0, errorString(C.NVML_ERROR_NOT_SUPPORTED)
+}
+
+// MultiGPUBoard returns whether the device is on a multi-GPU board.
+func (d Device) MultiGPUBoard() (bool, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetMultiGpuBoard_dl(d.dev, &n)
+	return n != 0, errorString(r)
+}
+
+// BoardID returns the id of the board of the device. Devices on the same
+// board share the id.
+func (d Device) BoardID() (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetBoardId_dl(d.dev, &n)
+	return uint(n), errorString(r)
+}
+
+// Serial returns the serial number of the board of the device.
+func (d Device) Serial() (string, error) {
+	var serial [szSerial]C.char
+	r := C.nvmlDeviceGetSerial_dl(d.dev, &serial[0], szSerial)
+	return C.GoString(&serial[0]), errorString(r)
+}
+
+// VbiosVersion returns the version of the VBIOS of the device.
+func (d Device) VbiosVersion() (string, error) {
+	var version [szVbios]C.char
+	r := C.nvmlDeviceGetVbiosVersion_dl(d.dev, &version[0], szVbios)
+	return C.GoString(&version[0]), errorString(r)
+}
+
+// PCIInfo returns the PCI attributes of the device.
+func (d Device) PCIInfo() (PCIInfo, error) {
+	var pci C.nvmlPciInfo_t
+	r := C.nvmlDeviceGetPciInfo_dl(d.dev, &pci)
+	return newPCIInfo(&pci), errorString(r)
+}
+
+// BoardPartNumber returns the part number of the board of the device.
+func (d Device) BoardPartNumber() (string, error) {
+	var part [szPart]C.char
+	r := C.nvmlDeviceGetBoardPartNumber_dl(d.dev, &part[0], szPart)
+	return C.GoString(&part[0]), errorString(r)
+}
+
+// newPCIInfo converts a nvmlPciInfo_t.
+func newPCIInfo(pci *C.nvmlPciInfo_t) PCIInfo {
+	return PCIInfo{
+		BusID:       C.GoString(&pci.busId[0]),
+		Domain:      uint(pci.domain),
+		Bus:         uint(pci.bus),
+		Device:      uint(pci.device),
+		DeviceID:    uint32(pci.pciDeviceId),
+		SubsystemID: uint32(pci.pciSubSystemId),
+	}
+}
+
+// CudaComputeCapability returns the CUDA compute capability of the device.
+func (d Device) CudaComputeCapability() (int, int, error) {
+	var major, minor C.int
+	r := C.nvmlDeviceGetCudaComputeCapability_dl(d.dev, &major, &minor)
+	return int(major), int(minor), errorString(r)
+}
+
+// ProcessUtilization returns the utilization of the device by each process
+// since lastSeenTimeStamp, a CPU timestamp in microseconds.
+func (d Device) ProcessUtilization(lastSeenTimeStamp uint64) ([]ProcessUtilizationSample, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetProcessUtilization_dl(d.dev, nil, &n, C.ulonglong(lastSeenTimeStamp))
+	if r != C.NVML_ERROR_INSUFFICIENT_SIZE || n == 0 {
+		return nil, errorString(r)
+	}
+	samples := make([]C.nvmlProcessUtilizationSample_t, n)
+	r = C.nvmlDeviceGetProcessUtilization_dl(d.dev, &samples[0], &n, C.ulonglong(lastSeenTimeStamp))
+	if err := errorString(r); err != nil {
+		return nil, err
+	}
+	result := make([]ProcessUtilizationSample, n)
+	for i := range result {
+		result[i] = ProcessUtilizationSample{
+			PID:       uint(samples[i].pid),
+			TimeStamp: uint64(samples[i].timeStamp),
+			SMUtil:    uint(samples[i].smUtil),
+			MemUtil:   uint(samples[i].memUtil),
+			EncUtil:   uint(samples[i].encUtil),
+			DecUtil:   uint(samples[i].decUtil),
+		}
+	}
+	return result, nil
+}
+
+// TemperatureThreshold returns a temperature threshold of the device in
+// Celsius.
+func (d Device) TemperatureThreshold(t TemperatureThreshold) (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetTemperatureThreshold_dl(d.dev, C.nvmlTemperatureThresholds_t(t), &n)
+	return uint(n), errorString(r)
+}
+
+// Brand returns the brand of the device, one of the NVML_BRAND_* values.
+func (d Device) Brand() (uint, error) {
+	var brand C.nvmlBrandType_t
+	r := C.nvmlDeviceGetBrand_dl(d.dev, &brand)
+	return uint(brand), errorString(r)
+}
+
+// Architecture returns the architecture of the device, one of the
+// NVML_DEVICE_ARCH_* values.
+func (d Device) Architecture() (uint, error) {
+	var arch C.nvmlDeviceArchitecture_t
+	r := C.nvmlDeviceGetArchitecture_dl(d.dev, &arch)
+	return uint(arch), errorString(r)
+}
+
+// Attributes returns the static attributes of the device or MIG device.
+func (d Device) Attributes() (DeviceAttributes, error) {
+	var attributes C.nvmlDeviceAttributes_t
+	r := C.nvmlDeviceGetAttributes_dl(d.dev, &attributes)
+	return DeviceAttributes{
+		MultiprocessorCount:       uint(attributes.multiprocessorCount),
+		GpuInstanceSliceCount:     uint(attributes.gpuInstanceSliceCount),
+		ComputeInstanceSliceCount: uint(attributes.computeInstanceSliceCount),
+		MemorySizeMB:              uint64(attributes.memorySizeMB),
+	}, errorString(r)
+}
+
+// NumGpuCores returns the number of CUDA cores of the device.
+func (d Device) NumGpuCores() (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetNumGpuCores_dl(d.dev, &n)
+	return uint(n), errorString(r)
+}
+
+// NvLinkState returns whether an NVLink of the device is active.
+func (d Device) NvLinkState(link uint) (bool, error) {
+	var active C.nvmlEnableState_t
+	r := C.nvmlDeviceGetNvLinkState_dl(d.dev, C.uint(link), &active)
+	return active == C.NVML_FEATURE_ENABLED, errorString(r)
+}
+
+// NvLinkThroughput returns the data transmitted and received over an NVLink
+// of the device in KiB.
+func (d Device) NvLinkThroughput(link uint) (uint64, uint64, error) {
+	fields, err := d.fieldValues(
+		[]uint{fieldNvLinkThroughputDataTx, fieldNvLinkThroughputDataRx},
+		[]uint{link, link},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, f := range fields {
+		if f.Err != nil {
+			return 0, 0, f.Err
+		}
+	}
+	tx := valueUint64(C.nvmlValueType_t(fields[0].ValueType), unsafe.Pointer(&fields[0].Value))
+	rx := valueUint64(C.nvmlValueType_t(fields[1].ValueType), unsafe.Pointer(&fields[1].Value))
+	return tx, rx, nil
+}
+
+// NvLinkErrorCounter returns an error counter of an NVLink of the device.
+func (d Device) NvLinkErrorCounter(link uint, counter NvLinkErrorCounter) (uint64, error) {
+	var n C.ulonglong
+	r := C.nvmlDeviceGetNvLinkErrorCounter_dl(d.dev, C.uint(link), C.nvmlNvLinkErrorCounter_t(counter), &n)
+	return uint64(n), errorString(r)
+}
+
+// NvLinkRemotePCIInfo returns the PCI attributes of the device at the other
+// end of an NVLink.
+func (d Device) NvLinkRemotePCIInfo(link uint) (PCIInfo, error) {
+	var pci C.nvmlPciInfo_t
+	r := C.nvmlDeviceGetNvLinkRemotePciInfo_dl(d.dev, C.uint(link), &pci)
+	return newPCIInfo(&pci), errorString(r)
+}
+
+// NvLinkRemoteDeviceType returns the type of the device at the other end of
+// an NVLink, one of the NVML_NVLINK_DEVICE_TYPE_* values.
+func (d Device) NvLinkRemoteDeviceType(link uint) (uint, error) {
+	var t C.nvmlIntNvLinkDeviceType_t
+	r := C.nvmlDeviceGetNvLinkRemoteDeviceType_dl(d.dev, C.uint(link), &t)
+	return uint(t), errorString(r)
+}
+
+// C2CLinkCount returns the number of C2C links of the device.
+func (d Device) C2CLinkCount() (uint, error) {
+	n, err := d.fieldUint(fieldC2CLinkCount, 0)
+	return uint(n), err
+}
+
+// C2CLinkStatus returns whether the C2C links of the device are active.
+func (d Device) C2CLinkStatus() (bool, error) {
+	n, err := d.fieldUint(fieldC2CLinkGetStatus, 0)
+	return n != 0, err
+}
+
+// C2CLinkMaxBandwidth returns the bandwidth of an active C2C link of the
+// device in MB/s.
+func (d Device) C2CLinkMaxBandwidth(link uint) (uint, error) {
+	n, err := d.fieldUint(fieldC2CLinkGetMaxBW, link)
+	return uint(n), err
+}
+
+// DisplayActive returns whether a display is initialized on the device.
+func (d Device) DisplayActive() (bool, error) {
+	var active C.nvmlEnableState_t
+	r := C.nvmlDeviceGetDisplayActive_dl(d.dev, &active)
+	return active == C.NVML_FEATURE_ENABLED, errorString(r)
+}
+
+// DisplayMode returns whether a display is connected to the device.
+func (d Device) DisplayMode() (bool, error) {
+	var mode C.nvmlEnableState_t
+	r := C.nvmlDeviceGetDisplayMode_dl(d.dev, &mode)
+	return mode == C.NVML_FEATURE_ENABLED, errorString(r)
+}
+
+// VirtualizationMode returns the virtualization mode of the device, one of
+// the NVML_GPU_VIRTUALIZATION_MODE_* values.
+func (d Device) VirtualizationMode() (uint, error) {
+	var mode C.nvmlGpuVirtualizationMode_t
+	r := C.nvmlDeviceGetVirtualizationMode_dl(d.dev, &mode)
+	return uint(mode), errorString(r)
+}
+
+// HostVgpuMode returns whether the device is in SR-IOV mode, one of the
+// NVML_HOST_VGPU_MODE_* values.
+func (d Device) HostVgpuMode() (uint, error) {
+	var mode C.nvmlHostVgpuMode_t
+	r := C.nvmlDeviceGetHostVgpuMode_dl(d.dev, &mode)
+	return uint(mode), errorString(r)
+}
+
+// PowerManagementLimitConstraints returns the minimum and maximum power
+// limits of the device in milliwatts.
+func (d Device) PowerManagementLimitConstraints() (uint, uint, error) {
+	var minLimit, maxLimit C.uint
+	r := C.nvmlDeviceGetPowerManagementLimitConstraints_dl(d.dev, &minLimit, &maxLimit)
+	return uint(minLimit), uint(maxLimit), errorString(r)
+}
+
+// PowerManagementDefaultLimit returns the default power limit of the device
+// in milliwatts.
+func (d Device) PowerManagementDefaultLimit() (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetPowerManagementDefaultLimit_dl(d.dev, &n)
+	return uint(n), errorString(r)
+}
+
+// EnforcedPowerLimit returns the power limit enforced by the driver in
+// milliwatts.
+func (d Device) EnforcedPowerLimit() (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetEnforcedPowerLimit_dl(d.dev, &n)
+	return uint(n), errorString(r)
+}
+
+// PowerState returns the current performance state of the device, from 0
+// (P0, maximum performance) to 15.
+func (d Device) PowerState() (uint, error) {
+	var pstate C.nvmlPstates_t
+	r := C.nvmlDeviceGetPerformanceState_dl(d.dev, &pstate)
+	return uint(pstate), errorString(r)
+}
+
+// PowerSource returns the power source of the device, one of the
+// NVML_POWER_SOURCE_* values.
+func (d Device) PowerSource() (uint, error) {
+	var source C.nvmlPowerSource_t
+	r := C.nvmlDeviceGetPowerSource_dl(d.dev, &source)
+	return uint(source), errorString(r)
+}
+
+// MigMode returns whether MIG mode is currently enabled and whether it will
+// be enabled after the next reset.
+func (d Device) MigMode() (bool, bool, error) {
+	var current, pending C.uint
+	r := C.nvmlDeviceGetMigMode_dl(d.dev, &current, &pending)
+	return current == C.NVML_DEVICE_MIG_ENABLE, pending == C.NVML_DEVICE_MIG_ENABLE, errorString(r)
+}
+
+// MaxMigDeviceCount returns the maximum number of MIG devices of the device.
+func (d Device) MaxMigDeviceCount() (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetMaxMigDeviceCount_dl(d.dev, &n)
+	return uint(n), errorString(r)
+}
+
+// MigDeviceHandleByIndex returns the handle of a MIG device of the device.
+// The indices range from 0 to MaxMigDeviceCount()-1; indices without a MIG
+// device return an "nvml: Not Found" error.
+func (d Device) MigDeviceHandleByIndex(idx uint) (Device, error) {
+	var mig C.nvmlDevice_t
+	r := C.nvmlDeviceGetMigDeviceHandleByIndex_dl(d.dev, C.uint(idx), &mig)
+	return Device{mig}, errorString(r)
+}
+
+// GpuInstanceID returns the GPU instance id of a MIG device.
+func (d Device) GpuInstanceID() (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetGpuInstanceId_dl(d.dev, &n)
+	return uint(n), errorString(r)
+}
+
+// ComputeInstanceID returns the compute instance id of a MIG device.
+func (d Device) ComputeInstanceID() (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetComputeInstanceId_dl(d.dev, &n)
+	return uint(n), errorString(r)
+}
+
+// ActiveVgpus returns the vGPU instances running on the device.
+func (d Device) ActiveVgpus() ([]VgpuInstance, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetActiveVgpus_dl(d.dev, &n, nil)
+	if r != C.NVML_ERROR_INSUFFICIENT_SIZE || n == 0 {
+		return nil, errorString(r)
+	}
+	instances := make([]C.nvmlVgpuInstance_t, n)
+	r = C.nvmlDeviceGetActiveVgpus_dl(d.dev, &n, &instances[0])
+	if err := errorString(r); err != nil {
+		return nil, err
+	}
+	result := make([]VgpuInstance, n)
+	for i := range result {
+		result[i] = VgpuInstance(instances[i])
+	}
+	return result, nil
+}
+
+// FbUsage returns the frame buffer used by the vGPU instance in bytes.
+func (v VgpuInstance) FbUsage() (uint64, error) {
+	var n C.ulonglong
+	r := C.nvmlVgpuInstanceGetFbUsage_dl(C.nvmlVgpuInstance_t(v), &n)
+	return uint64(n), errorString(r)
+}
+
+// VMID returns the id of the VM the vGPU instance is assigned to.
+func (v VgpuInstance) VMID() (string, error) {
+	var id [szVMID]C.char
+	var t C.nvmlVgpuVmIdType_t
+	r := C.nvmlVgpuInstanceGetVmID_dl(C.nvmlVgpuInstance_t(v), &id[0], szVMID, &t)
+	return C.GoString(&id[0]), errorString(r)
+}
+
+// GridLicensableFeatures returns the vGPU software licensable features of the
+// device.
+func (d Device) GridLicensableFeatures() ([]GridLicensableFeature, error) {
+	var features C.nvmlGridLicensableFeatures_t
+	r := C.nvmlDeviceGetGridLicensableFeatures_dl(d.dev, &features)
+	if err := errorString(r); err != nil {
+		return nil, err
+	}
+	n := int(features.licensableFeaturesCount)
+	if n > len(features.gridLicensableFeatures) {
+		n = len(features.gridLicensableFeatures)
+	}
+	result := make([]GridLicensableFeature, n)
+	for i := range result {
+		f := &features.gridLicensableFeatures[i]
+		result[i] = GridLicensableFeature{
+			FeatureCode: uint(f.featureCode),
+			Licensed:    f.featureState != 0,
+			Enabled:     f.featureEnabled != 0,
+			ProductName: C.GoString(&f.productName[0]),
+		}
+		if e := f.licenseExpiry; e.status == C.NVML_GRID_LICENSE_EXPIRY_VALID {
+			result[i].LicenseExpiry = time.Date(int(e.year), time.Month(e.month), int(e.day),
+				int(e.hour), int(e.min), int(e.sec), 0, time.UTC)
+		}
+	}
+	return result, nil
+}
+
+// ViolationStatus returns the time in ns during which the clocks of the device
+// were reduced by the given policy, and the CPU timestamp in microseconds at
+// which it was last reset.
+func (d Device) ViolationStatus(policy PerfPolicyType) (referenceTime, violationTime uint64, err error) {
+	var t C.nvmlViolationTime_t
+	r := C.nvmlDeviceGetViolationStatus_dl(d.dev, C.nvmlPerfPolicyType_t(policy), &t)
+	return uint64(t.referenceTime), uint64(t.violationTime), errorString(r)
+}
+
+// MemoryInfoV2 returns the memory of the device in bytes, including the
+// memory reserved by the driver and firmware. Unlike MemoryInfo, the used
+// memory doesn't include the reserved memory.
+func (d Device) MemoryInfoV2() (MemoryInfoV2, error) {
+	var memory C.nvmlMemory_v2_t
+	r := C.nvmlDeviceGetMemoryInfoV2_dl(d.dev, &memory)
+	return MemoryInfoV2{
+		Total:    uint64(memory.total),
+		Reserved: uint64(memory.reserved),
+		Free:     uint64(memory.free),
+		Used:     uint64(memory.used),
+	}, errorString(r)
+}
+
+// GspFirmwareMode returns whether the GSP firmware is enabled on the device
+// and whether it is enabled by default.
+func (d Device) GspFirmwareMode() (enabled, defaultEnabled bool, err error) {
+	var isEnabled, defaultMode C.uint
+	r := C.nvmlDeviceGetGspFirmwareMode_dl(d.dev, &isEnabled, &defaultMode)
+	return isEnabled != 0, defaultMode != 0, errorString(r)
+}
+
+// RemappedRows returns the number of rows of the memory of the device that
+// were remapped because of correctable and uncorrectable errors, whether a
+// remapping is pending and whether a remapping failed.
+func (d Device) RemappedRows() (corrRows, uncRows uint, isPending, failureOccurred bool, err error) {
+	var corr, unc, pending, failure C.uint
+	r := C.nvmlDeviceGetRemappedRows_dl(d.dev, &corr, &unc, &pending, &failure)
+	return uint(corr), uint(unc), pending != 0, failure != 0, errorString(r)
+}
+
+// GpuFabricInfo returns the state of the device in the NVLink fabric.
+func (d Device) GpuFabricInfo() (GpuFabricInfo, error) {
+	var info C.nvmlGpuFabricInfo_t
+	r := C.nvmlDeviceGetGpuFabricInfo_dl(d.dev, &info)
+	u := *(*[C.NVML_GPU_FABRIC_UUID_LEN]byte)(unsafe.Pointer(&info.clusterUuid[0]))
+	return GpuFabricInfo{
+		ClusterUUID: fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]),
+		CliqueID:    uint(info.cliqueId),
+		State:       uint(info.state),
+		Status:      errorString(info.status),
+	}, errorString(r)
+}
+
+// EccMode returns whether ECC is currently enabled on the device and whether
+// it will be enabled after the next reboot.
+func (d Device) EccMode() (current, pending bool, err error) {
+	var c, p C.nvmlEnableState_t
+	r := C.nvmlDeviceGetEccMode_dl(d.dev, &c, &p)
+	return c == C.NVML_FEATURE_ENABLED, p == C.NVML_FEATURE_ENABLED, errorString(r)
+}
+
+// AccountingMode returns whether accounting mode is enabled on the device.
+func (d Device) AccountingMode() (bool, error) {
+	var mode C.nvmlEnableState_t
+	r := C.nvmlDeviceGetAccountingMode_dl(d.dev, &mode)
+	return mode == C.NVML_FEATURE_ENABLED, errorString(r)
+}
+
+// AccountingPids returns the processes in the accounting buffer of the
+// device.
+func (d Device) AccountingPids() ([]uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetAccountingPids_dl(d.dev, &n, nil)
+	if r != C.NVML_ERROR_INSUFFICIENT_SIZE || n == 0 {
+		return nil, errorString(r)
+	}
+	pids := make([]C.uint, n)
+	r = C.nvmlDeviceGetAccountingPids_dl(d.dev, &n, &pids[0])
+	if err := errorString(r); err != nil {
+		return nil, err
+	}
+	result := make([]uint, n)
+	for i := range result {
+		result[i] = uint(pids[i])
+	}
+	return result, nil
+}
+
+// AccountingStats returns the accounting statistics of a process.
+func (d Device) AccountingStats(pid uint) (AccountingStats, error) {
+	var stats C.nvmlAccountingStats_t
+	r := C.nvmlDeviceGetAccountingStats_dl(d.dev, C.uint(pid), &stats)
+	return AccountingStats{
+		GPUUtilization:    uint(stats.gpuUtilization),
+		MemoryUtilization: uint(stats.memoryUtilization),
+		MaxMemoryUsage:    uint64(stats.maxMemoryUsage),
+		Time:              uint64(stats.time),
+		StartTime:         uint64(stats.startTime),
+		IsRunning:         stats.isRunning != 0,
+	}, errorString(r)
+}
+
+// AccountingBufferSize returns the number of processes the accounting buffer
+// of the device holds.
+func (d Device) AccountingBufferSize() (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetAccountingBufferSize_dl(d.dev, &n)
+	return uint(n), errorString(r)
+}
+
+// ComputeRunningProcesses returns the processes with a compute context on
+// the device.
+func (d Device) ComputeRunningProcesses() ([]ProcessInfo, error) {
+	return d.runningProcesses(false)
+}
+
+// GraphicsRunningProcesses returns the processes with a graphics context on
+// the device.
+func (d Device) GraphicsRunningProcesses() ([]ProcessInfo, error) {
+	return d.runningProcesses(true)
+}
+
+func (d Device) runningProcesses(graphics bool) ([]ProcessInfo, error) {
+	get := func(n *C.uint, infos *C.nvmlProcessInfo_t) C.nvmlReturn_t {
+		if graphics {
+			return C.nvmlDeviceGetGraphicsRunningProcesses_dl(d.dev, n, infos)
+		}
+		return C.nvmlDeviceGetComputeRunningProcesses_dl(d.dev, n, infos)
+	}
+	var n C.uint
+	r := get(&n, nil)
+	if r != C.NVML_ERROR_INSUFFICIENT_SIZE || n == 0 {
+		return nil, errorString(r)
+	}
+	infos := make([]C.nvmlProcessInfo_t, n)
+	if err := errorString(get(&n, &infos[0])); err != nil {
+		return nil, err
+	}
+	result := make([]ProcessInfo, n)
+	for i := range result {
+		result[i] = ProcessInfo{
+			PID:           uint(infos[i].pid),
+			UsedGPUMemory: uint64(infos[i].usedGpuMemory),
+		}
+	}
+	return result, nil
+}
+
+// ApplicationsClock returns the clock the device runs applications at in MHz.
+func (d Device) ApplicationsClock(clockType ClockType) (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetApplicationsClock_dl(d.dev, C.nvmlClockType_t(clockType), &n)
+	return uint(n), errorString(r)
+}
+
+// DefaultApplicationsClock returns the default clock the device runs
+// applications at in MHz.
+func (d Device) DefaultApplicationsClock(clockType ClockType) (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetDefaultApplicationsClock_dl(d.dev, C.nvmlClockType_t(clockType), &n)
+	return uint(n), errorString(r)
+}
+
+// MaxCustomerBoostClock returns the maximum clock the device boosts to in MHz.
+func (d Device) MaxCustomerBoostClock(clockType ClockType) (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetMaxCustomerBoostClock_dl(d.dev, C.nvmlClockType_t(clockType), &n)
+	return uint(n), errorString(r)
+}
+
+// SupportedPerformanceStates returns the performance states the device
+// supports, from 0 (P0, maximum performance) to 15.
+func (d Device) SupportedPerformanceStates() ([]uint, error) {
+	var pstates [maxPstates]C.nvmlPstates_t
+	r := C.nvmlDeviceGetSupportedPerformanceStates_dl(d.dev, &pstates[0], C.uint(len(pstates)*int(unsafe.Sizeof(pstates[0]))))
+	if err := errorString(r); err != nil {
+		return nil, err
+	}
+	var result []uint
+	for _, pstate := range pstates {
+		if pstate != C.NVML_PSTATE_UNKNOWN {
+			result = append(result, uint(pstate))
+		}
+	}
+	return result, nil
+}
+
+// ClockOffsets returns the offset of a clock of the device in a performance
+// state and its allowed range, in MHz.
+func (d Device) ClockOffsets(clockType ClockType, pstate uint) (ClockOffset, error) {
+	var info C.nvmlClockOffset_t
+	r := C.nvmlDeviceGetClockOffsetsV1_dl(d.dev, C.nvmlClockType_t(clockType), C.nvmlPstates_t(pstate), &info)
+	return ClockOffset{
+		Offset: int(info.clockOffsetMHz),
+		Min:    int(info.minClockOffsetMHz),
+		Max:    int(info.maxClockOffsetMHz),
+	}, errorString(r)
+}
+
+// GpuOperationMode returns the current and pending operation mode of the
+// device, one of the NVML_GOM_* values.
+func (d Device) GpuOperationMode() (current, pending uint, err error) {
+	var c, p C.nvmlGpuOperationMode_t
+	r := C.nvmlDeviceGetGpuOperationMode_dl(d.dev, &c, &p)
+	return uint(c), uint(p), errorString(r)
+}
+
+// DriverModel returns the current and pending driver model of the device, one
+// of the NVML_DRIVER_* values. Only supported on Windows.
+func (d Device) DriverModel() (current, pending uint, err error) {
+	var c, p C.nvmlDriverModel_t
+	r := C.nvmlDeviceGetDriverModel_dl(d.dev, &c, &p)
+	return uint(c), uint(p), errorString(r)
+}
+
+// NumaNodeID returns the NUMA node of the device.
+func (d Device) NumaNodeID() (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetNumaNodeId_dl(d.dev, &n)
+	return uint(n), errorString(r)
+}
+
+// IrqNum returns the interrupt number of the device.
+func (d Device) IrqNum() (uint, error) {
+	var n C.uint
+	r := C.nvmlDeviceGetIrqNum_dl(d.dev, &n)
+	return uint(n), errorString(r)
+}
+
+// EncoderStats returns the number of active encoder sessions of the device,
+// their average frame rate and their average latency in microseconds.
+func (d Device) EncoderStats() (sessionCount, averageFps, averageLatency uint, err error) {
+	var sessions, fps, latency C.uint
+	r := C.nvmlDeviceGetEncoderStats_dl(d.dev, &sessions, &fps, &latency)
+	return uint(sessions), uint(fps), uint(latency), errorString(r)
+}
+
+// InforomVersion returns the version of an InfoROM object of the device, one
+// of the Inforom* constants.
+func (d Device) InforomVersion(object uint) (string, error) {
+	var version [szInforom]C.char
+	r := C.nvmlDeviceGetInforomVersion_dl(d.dev, C.nvmlInforomObject_t(object), &version[0], szInforom)
+	return C.GoString(&version[0]), errorString(r)
+}
+
+// InforomImageVersion returns the version of the InfoROM image of the device.
+func (d Device) InforomImageVersion() (string, error) {
+	var version [szInforom]C.char
+	r := C.nvmlDeviceGetInforomImageVersion_dl(d.dev, &version[0], szInforom)
+	return C.GoString(&version[0]), errorString(r)
+}
+
+// ValidateInforom checks the integrity of the InfoROM of the device. It
+// returns an "nvml: Corrupted infoROM" error if the check fails.
+func (d Device) ValidateInforom() error {
+	return errorString(C.nvmlDeviceValidateInforom_dl(d.dev))
+}
+
+// ThermalSettings returns the settings and temperatures of the thermal
+// sensors of the device.
+func (d Device) ThermalSettings() (ThermalSettings, error) {
+	var settings C.nvmlGpuThermalSettings_t
+	r := C.nvmlDeviceGetThermalSettings_dl(d.dev, C.NVML_THERMAL_TARGET_ALL, &settings)
+	if err := errorString(r); err != nil {
+		return ThermalSettings{}, err
+	}
+	thermal := ThermalSettings{Count: uint(settings.count)}
+	for i := range thermal.Sensors {
+		s := &settings.sensor[i]
+		thermal.Sensors[i] = ThermalSensor{
+			Controller:     int(s.controller),
+			DefaultMinTemp: int(s.defaultMinTemp),
+			DefaultMaxTemp: int(s.defaultMaxTemp),
+			CurrentTemp:    int(s.currentTemp),
+			Target:         uint(s.target),
+		}
+	}
+	return thermal, nil
+}
@@ -0,0 +1,536 @@
+//go:build !cgo
+// +build !cgo
+
+package gonvml
+
+import "time"
+
+// Unit is the handle for an S-class unit.
+// This handle is obtained by calling UnitHandleByIndex().
+type Unit struct {
+}
+
+// EventSet is a set of devices and event types to wait for.
+// It is obtained by calling NewEventSet().
+type EventSet struct {
+}
+
+// GpmSample is a buffer holding a GPM sample.
+// It is obtained by calling GpmSampleAlloc().
+type GpmSample struct {
+}
+
+// SystemNVMLVersion returns the version of the NVML library.
+func SystemNVMLVersion() (string, error) {
+	return "", errNoCgo
+}
+
+// SystemCudaDriverVersion returns the version of the CUDA driver, encoded as
+// 1000*major + 10*minor.
+func SystemCudaDriverVersion() (int, error) {
+	return 0, errNoCgo
+}
+
+// ExcludedDeviceCount returns the number of devices excluded from use by
+// NVML.
+func ExcludedDeviceCount() (uint, error) {
+	return 0, errNoCgo
+}
+
+// ExcludedDeviceInfoByIndex returns the PCI bus id and UUID of an excluded
+// device. The indices range from 0 to ExcludedDeviceCount()-1.
+func ExcludedDeviceInfoByIndex(idx uint) (ExcludedDeviceInfo, error) {
+	return ExcludedDeviceInfo{}, errNoCgo
+}
+
+// SystemConfComputeState returns the confidential computing state of the
+// system.
+func SystemConfComputeState() (ConfComputeState, error) {
+	return ConfComputeState{}, errNoCgo
+}
+
+// SystemConfComputeGpusReadyState returns whether the devices accept work in
+// confidential computing mode.
+func SystemConfComputeGpusReadyState() (bool, error) {
+	return false, errNoCgo
+}
+
+// UnitCount returns the number of S-class units on the system.
+func UnitCount() (uint, error) {
+	return 0, errNoCgo
+}
+
+// UnitHandleByIndex returns the handle for a particular unit. The indices
+// range from 0 to UnitCount()-1.
+func UnitHandleByIndex(idx uint) (Unit, error) {
+	return Unit{}, errNoCgo
+}
+
+// Info returns the static information of the unit.
+func (u Unit) Info() (UnitInfo, error) {
+	return UnitInfo{}, errNoCgo
+}
+
+// PsuInfo returns the state of the power supply of the unit.
+func (u Unit) PsuInfo() (PSUInfo, error) {
+	return PSUInfo{}, errNoCgo
+}
+
+// FanSpeedInfo returns the state of the fans of the unit.
+func (u Unit) FanSpeedInfo() ([]UnitFanInfo, error) {
+	return nil, errNoCgo
+}
+
+// Temperature returns the temperature of a sensor of the unit in Celsius.
+func (u Unit) Temperature(sensor UnitTemperatureType) (uint, error) {
+	return 0, errNoCgo
+}
+
+// NewEventSet creates an empty event set.
+func NewEventSet() (EventSet, error) {
+	return EventSet{}, errNoCgo
+}
+
+// Free releases the event set.
+func (s EventSet) Free() error {
+	return errNoCgo
+}
+
+// Wait waits up to timeout for an event of one of the registered devices. It
+// returns an "nvml: Timeout" error if no event occurred.
+func (s EventSet) Wait(timeout time.Duration) (EventData, error) {
+	return EventData{}, errNoCgo
+}
+
+// SupportedEventTypes returns the event types the device can deliver, as a
+// mask of the EventType constants.
+func (d Device) SupportedEventTypes() (uint64, error) {
+	return 0, errNoCgo
+}
+
+// RegisterEvents registers the device for the given event types with the set.
+func (d Device) RegisterEvents(eventTypes uint64, set EventSet) error {
+	return errNoCgo
+}
+
+// GpmSampleAlloc allocates a GPM sample buffer.
+func GpmSampleAlloc() (GpmSample, error) {
+	return GpmSample{}, errNoCgo
+}
+
+// Free releases the sample buffer.
+func (s GpmSample) Free() error {
+	return errNoCgo
+}
+
+// GpmSampleGet reads a GPM sample of the device into the buffer.
+func (d Device) GpmSampleGet(sample GpmSample) error {
+	return errNoCgo
+}
+
+// GpmQueryDeviceSupport returns whether the device supports GPM.
+func (d Device) GpmQueryDeviceSupport() (bool, error) {
+	return false, errNoCgo
+}
+
+// GpmMetricsGet computes the given metrics from two samples of the same
+// device.
+func GpmMetricsGet(sample1, sample2 GpmSample, metricIDs []GpmMetricID) ([]GpmMetric, error) {
+	return nil, errNoCgo
+}
+
+// FieldName returns the name of a common field, e.g. "dev_ecc_current", or
+// an empty string for other fields.
+func FieldName(fieldID uint) string {
+	return ""
+}
+
+// FieldValues reads the given fields of the device. The error of each field
+// is returned in its Err.
+func (d Device) FieldValues(fieldIDs []uint) ([]FieldValue, error) {
+	return nil, errNoCgo
+}
+
+// Samples returns the samples of the given type that the driver recorded
+// after lastSeenTimeStamp, a CPU timestamp in microseconds.
+func (d Device) Samples(samplingType SamplingType, lastSeenTimeStamp uint64) ([]Sample, error) {
+	return nil, errNoCgo
+}
+
+// MemoryTemperature returns the temperature of the memory of the device in
+// Celsius. Only devices with a separate memory sensor, e.g. HBM, report it.
+func (d Device) MemoryTemperature() (uint, error) {
+	return 0, errNoCgo
+}
+
+// HotspotTemperature returns the hotspot temperature of the device in
+// Celsius. NVML doesn't expose the hotspot sensor yet, so this always returns
+// an "nvml: Not Supported" error.
+func (d Device) HotspotTemperature() (uint, error) {
+	return 0, errNoCgo
+}
+
+// MultiGPUBoard returns whether the device is on a multi-GPU board.
+func (d Device) MultiGPUBoard() (bool, error) {
+	return false, errNoCgo
+}
+
+// BoardID returns the id of the board of the device. Devices on the same
+// board share the id.
+func (d Device) BoardID() (uint, error) {
+	return 0, errNoCgo
+}
+
+// Serial returns the serial number of the board of the device.
+func (d Device) Serial() (string, error) {
+	return "", errNoCgo
+}
+
+// VbiosVersion returns the version of the VBIOS of the device.
+func (d Device) VbiosVersion() (string, error) {
+	return "", errNoCgo
+}
+
+// PCIInfo returns the PCI attributes of the device.
+func (d Device) PCIInfo() (PCIInfo, error) {
+	return PCIInfo{}, errNoCgo
+}
+
+// BoardPartNumber returns the part number of the board of the device.
+func (d Device) BoardPartNumber() (string, error) {
+	return "", errNoCgo
+}
+
+// CudaComputeCapability returns the CUDA compute capability of the device.
+func (d Device) CudaComputeCapability() (int, int, error) {
+	return 0, 0, errNoCgo
+}
+
+// ProcessUtilization returns the utilization of the device by each process
+// since lastSeenTimeStamp, a CPU timestamp in microseconds.
+func (d Device) ProcessUtilization(lastSeenTimeStamp uint64) ([]ProcessUtilizationSample, error) {
+	return nil, errNoCgo
+}
+
+// TemperatureThreshold returns a temperature threshold of the device in
+// Celsius.
+func (d Device) TemperatureThreshold(t TemperatureThreshold) (uint, error) {
+	return 0, errNoCgo
+}
+
+// Brand returns the brand of the device, one of the NVML_BRAND_* values.
+func (d Device) Brand() (uint, error) {
+	return 0, errNoCgo
+}
+
+// Architecture returns the architecture of the device, one of the
+// NVML_DEVICE_ARCH_* values.
+func (d Device) Architecture() (uint, error) {
+	return 0, errNoCgo
+}
+
+// Attributes returns the static attributes of the device or MIG device.
+func (d Device) Attributes() (DeviceAttributes, error) {
+	return DeviceAttributes{}, errNoCgo
+}
+
+// NumGpuCores returns the number of CUDA cores of the device.
+func (d Device) NumGpuCores() (uint, error) {
+	return 0, errNoCgo
+}
+
+// NvLinkState returns whether an NVLink of the device is active.
+func (d Device) NvLinkState(link uint) (bool, error) {
+	return false, errNoCgo
+}
+
+// NvLinkThroughput returns the data transmitted and received over an NVLink
+// of the device in KiB.
+func (d Device) NvLinkThroughput(link uint) (uint64, uint64, error) {
+	return 0, 0, errNoCgo
+}
+
+// NvLinkErrorCounter returns an error counter of an NVLink of the device.
+func (d Device) NvLinkErrorCounter(link uint, counter NvLinkErrorCounter) (uint64, error) {
+	return 0, errNoCgo
+}
+
+// NvLinkRemotePCIInfo returns the PCI attributes of the device at the other
+// end of an NVLink.
+func (d Device) NvLinkRemotePCIInfo(link uint) (PCIInfo, error) {
+	return PCIInfo{}, errNoCgo
+}
+
+// NvLinkRemoteDeviceType returns the type of the device at the other end of
+// an NVLink, one of the NVML_NVLINK_DEVICE_TYPE_* values.
+func (d Device) NvLinkRemoteDeviceType(link uint) (uint, error) {
+	return 0, errNoCgo
+}
+
+// C2CLinkCount returns the number of C2C links of the device.
+func (d Device) C2CLinkCount() (uint, error) {
+	return 0, errNoCgo
+}
+
+// C2CLinkStatus returns whether the C2C links of the device are active.
+func (d Device) C2CLinkStatus() (bool, error) {
+	return false, errNoCgo
+}
+
+// C2CLinkMaxBandwidth returns the bandwidth of an active C2C link of the
+// device in MB/s.
+func (d Device) C2CLinkMaxBandwidth(link uint) (uint, error) {
+	return 0, errNoCgo
+}
+
+// DisplayActive returns whether a display is initialized on the device.
+func (d Device) DisplayActive() (bool, error) {
+	return false, errNoCgo
+}
+
+// DisplayMode returns whether a display is connected to the device.
+func (d Device) DisplayMode() (bool, error) {
+	return false, errNoCgo
+}
+
+// VirtualizationMode returns the virtualization mode of the device, one of
+// the NVML_GPU_VIRTUALIZATION_MODE_* values.
+func (d Device) VirtualizationMode() (uint, error) {
+	return 0, errNoCgo
+}
+
+// HostVgpuMode returns whether the device is in SR-IOV mode, one of the
+// NVML_HOST_VGPU_MODE_* values.
+func (d Device) HostVgpuMode() (uint, error) {
+	return 0, errNoCgo
+}
+
+// PowerManagementLimitConstraints returns the minimum and maximum power
+// limits of the device in milliwatts.
+func (d Device) PowerManagementLimitConstraints() (uint, uint, error) {
+	return 0, 0, errNoCgo
+}
+
+// PowerManagementDefaultLimit returns the default power limit of the device
+// in milliwatts.
+func (d Device) PowerManagementDefaultLimit() (uint, error) {
+	return 0, errNoCgo
+}
+
+// EnforcedPowerLimit returns the power limit enforced by the driver in
+// milliwatts.
+func (d Device) EnforcedPowerLimit() (uint, error) {
+	return 0, errNoCgo
+}
+
+// PowerState returns the current performance state of the device, from 0
+// (P0, maximum performance) to 15.
+func (d Device) PowerState() (uint, error) {
+	return 0, errNoCgo
+}
+
+// PowerSource returns the power source of the device, one of the
+// NVML_POWER_SOURCE_* values.
+func (d Device) PowerSource() (uint, error) {
+	return 0, errNoCgo
+}
+
+// MigMode returns whether MIG mode is currently enabled and whether it will
+// be enabled after the next reset.
+func (d Device) MigMode() (bool, bool, error) {
+	return false, false, errNoCgo
+}
+
+// MaxMigDeviceCount returns the maximum number of MIG devices of the device.
+func (d Device) MaxMigDeviceCount() (uint, error) {
+	return 0, errNoCgo
+}
+
+// MigDeviceHandleByIndex returns the handle of a MIG device of the device.
+// The indices range from 0 to MaxMigDeviceCount()-1; indices without a MIG
+// device return an "nvml: Not Found" error.
+func (d Device) MigDeviceHandleByIndex(idx uint) (Device, error) {
+	return Device{}, errNoCgo
+}
+
+// GpuInstanceID returns the GPU instance id of a MIG device.
+func (d Device) GpuInstanceID() (uint, error) {
+	return 0, errNoCgo
+}
+
+// ComputeInstanceID returns the compute instance id of a MIG device.
+func (d Device) ComputeInstanceID() (uint, error) {
+	return 0, errNoCgo
+}
+
+// ActiveVgpus returns the vGPU instances running on the device.
+func (d Device) ActiveVgpus() ([]VgpuInstance, error) {
+	return nil, errNoCgo
+}
+
+// FbUsage returns the frame buffer used by the vGPU instance in bytes.
+func (v VgpuInstance) FbUsage() (uint64, error) {
+	return 0, errNoCgo
+}
+
+// VMID returns the id of the VM the vGPU instance is assigned to.
+func (v VgpuInstance) VMID() (string, error) {
+	return "", errNoCgo
+}
+
+// GridLicensableFeatures returns the vGPU software licensable features of the
+// device.
+func (d Device) GridLicensableFeatures() ([]GridLicensableFeature, error) {
+	return nil, errNoCgo
+}
+
+// ViolationStatus returns the time in ns during which the clocks of the device
+// were reduced by the given policy, and the CPU timestamp in microseconds at
+// which it was last reset.
+func (d Device) ViolationStatus(policy PerfPolicyType) (referenceTime, violationTime uint64, err error) {
+	return 0, 0, errNoCgo
+}
+
+// MemoryInfoV2 returns the memory of the device in bytes, including the
+// memory reserved by the driver and firmware. Unlike MemoryInfo, the used
+// memory doesn't include the reserved memory.
+func (d Device) MemoryInfoV2() (MemoryInfoV2, error) {
+	return MemoryInfoV2{}, errNoCgo
+}
+
+// GspFirmwareMode returns whether the GSP firmware is enabled on the device
+// and whether it is enabled by default.
+func (d Device) GspFirmwareMode() (enabled, defaultEnabled bool, err error) {
+	return false, false, errNoCgo
+}
+
+// RemappedRows returns the number of rows of the memory of the device that
+// were remapped because of correctable and uncorrectable errors, whether a
+// remapping is pending and whether a remapping failed.
+func (d Device) RemappedRows() (corrRows, uncRows uint, isPending, failureOccurred bool, err error) {
+	return 0, 0, false, false, errNoCgo
+}
+
+// GpuFabricInfo returns the state of the device in the NVLink fabric.
+func (d Device) GpuFabricInfo() (GpuFabricInfo, error) {
+	return GpuFabricInfo{}, errNoCgo
+}
+
+// EccMode returns whether ECC is currently enabled on the device and whether
+// it will be enabled after the next reboot.
+func (d Device) EccMode() (current, pending bool, err error) {
+	return false, false, errNoCgo
+}
+
+// AccountingMode returns whether accounting mode is enabled on the device.
+func (d Device) AccountingMode() (bool, error) {
+	return false, errNoCgo
+}
+
+// AccountingPids returns the processes in the accounting buffer of the
+// device.
+func (d Device) AccountingPids() ([]uint, error) {
+	return nil, errNoCgo
+}
+
+// AccountingStats returns the accounting statistics of a process.
+func (d Device) AccountingStats(pid uint) (AccountingStats, error) {
+	return AccountingStats{}, errNoCgo
+}
+
+// AccountingBufferSize returns the number of processes the accounting buffer
+// of the device holds.
+func (d Device) AccountingBufferSize() (uint, error) {
+	return 0, errNoCgo
+}
+
+// ComputeRunningProcesses returns the processes with a compute context on
+// the device.
+func (d Device) ComputeRunningProcesses() ([]ProcessInfo, error) {
+	return nil, errNoCgo
+}
+
+// GraphicsRunningProcesses returns the processes with a graphics context on
+// the device.
+func (d Device) GraphicsRunningProcesses() ([]ProcessInfo, error) {
+	return nil, errNoCgo
+}
+
+// ApplicationsClock returns the clock the device runs applications at in MHz.
+func (d Device) ApplicationsClock(clockType ClockType) (uint, error) {
+	return 0, errNoCgo
+}
+
+// DefaultApplicationsClock returns the default clock the device runs
+// applications at in MHz.
+func (d Device) DefaultApplicationsClock(clockType ClockType) (uint, error) {
+	return 0, errNoCgo
+}
+
+// MaxCustomerBoostClock returns the maximum clock the device boosts to in MHz.
+func (d Device) MaxCustomerBoostClock(clockType ClockType) (uint, error) {
+	return 0, errNoCgo
+}
+
+// SupportedPerformanceStates returns the performance states the device
+// supports, from 0 (P0, maximum performance) to 15.
+func (d Device) SupportedPerformanceStates() ([]uint, error) {
+	return nil, errNoCgo
+}
+
+// ClockOffsets returns the offset of a clock of the device in a performance
+// state and its allowed range, in MHz.
+func (d Device) ClockOffsets(clockType ClockType, pstate uint) (ClockOffset, error) {
+	return ClockOffset{}, errNoCgo
+}
+
+// GpuOperationMode returns the current and pending operation mode of the
+// device, one of the NVML_GOM_* values.
+func (d Device) GpuOperationMode() (current, pending uint, err error) {
+	return 0, 0, errNoCgo
+}
+
+// DriverModel returns the current and pending driver model of the device, one
+// of the NVML_DRIVER_* values. Only supported on Windows.
+func (d Device) DriverModel() (current, pending uint, err error) {
+	return 0, 0, errNoCgo
+}
+
+// NumaNodeID returns the NUMA node of the device.
+func (d Device) NumaNodeID() (uint, error) {
+	return 0, errNoCgo
+}
+
+// IrqNum returns the interrupt number of the device.
+func (d Device) IrqNum() (uint, error) {
+	return 0, errNoCgo
+}
+
+// EncoderStats returns the number of active encoder sessions of the device,
+// their average frame rate and their average latency in microseconds.
+func (d Device) EncoderStats() (sessionCount, averageFps, averageLatency uint, err error) {
+	return 0, 0, 0, errNoCgo
+}
+
+// InforomVersion returns the version of an InfoROM object of the device, one
+// of the Inforom* constants.
+func (d Device) InforomVersion(object uint) (string, error) {
+	return "", errNoCgo
+}
+
+// InforomImageVersion returns the version of the InfoROM image of the device.
+func (d Device) InforomImageVersion() (string, error) {
+	return "", errNoCgo
+}
+
+// ValidateInforom checks the integrity of the InfoROM of the device. It
+// returns an "nvml: Corrupted infoROM" error if the check fails.
+func (d Device) ValidateInforom() error {
+	return errNoCgo
+}
+
+// ThermalSettings returns the settings and temperatures of the thermal
+// sensors of the device.
+func (d Device) ThermalSettings() (ThermalSettings, error) {
+	return ThermalSettings{}, errNoCgo
+}
@@ -0,0 +1,3 @@
+module github.com/xofym/gonvml
+
+go 1.13
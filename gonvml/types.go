@@ -0,0 +1,371 @@
+/*
+Copyright 2017 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gonvml
+
+import "time"
+
+// The types and constants in this file are shared by the cgo and the non-cgo
+// builds. The values of the constants match the corresponding NVML enums.
+
+// AccountingStats describes a process that ran on the device while
+// accounting mode was enabled.
+type AccountingStats struct {
+	GPUUtilization    uint
+	MemoryUtilization uint
+	MaxMemoryUsage    uint64
+	// Time is the time in ms during which the process was active. It is 0
+	// while the process is running.
+	Time uint64
+	// StartTime is the CPU timestamp in microseconds of the start of the
+	// process.
+	StartTime uint64
+	IsRunning bool
+}
+
+// ProcessInfo describes a process that has a context on the device.
+type ProcessInfo struct {
+	PID uint
+	// UsedGPUMemory is the memory used by the process in bytes.
+	UsedGPUMemory uint64
+}
+
+// Event types that can be registered with RegisterEvents.
+const (
+	EventTypeSingleBitEccError uint64 = 0x1
+	EventTypeDoubleBitEccError uint64 = 0x2
+	EventTypeXidCriticalError  uint64 = 0x8
+)
+
+// EventData is an event delivered to an EventSet.
+type EventData struct {
+	Device    Device
+	EventType uint64
+	// EventData is the XID for EventTypeXidCriticalError and 0 otherwise.
+	EventData uint64
+}
+
+// PCIInfo holds the PCI attributes of a device.
+type PCIInfo struct {
+	// BusID is the domain:bus:device.function identifier.
+	BusID  string
+	Domain uint
+	Bus    uint
+	Device uint
+	// DeviceID combines the 16-bit device id and the 16-bit vendor id.
+	DeviceID    uint32
+	SubsystemID uint32
+}
+
+// ProcessUtilizationSample holds the utilization of the device by a process.
+type ProcessUtilizationSample struct {
+	PID uint
+	// TimeStamp is the CPU timestamp of the sample in microseconds.
+	TimeStamp uint64
+	SMUtil    uint
+	MemUtil   uint
+	EncUtil   uint
+	DecUtil   uint
+}
+
+// TemperatureThreshold is a temperature threshold of the device.
+type TemperatureThreshold int
+
+// Temperature thresholds.
+const (
+	TemperatureThresholdShutdown TemperatureThreshold = iota
+	TemperatureThresholdSlowdown
+)
+
+// DeviceAttributes holds the static attributes of a device or MIG device.
+type DeviceAttributes struct {
+	MultiprocessorCount       uint
+	GpuInstanceSliceCount     uint
+	ComputeInstanceSliceCount uint
+	MemorySizeMB              uint64
+}
+
+// NvLinkMaxLinks is the maximum number of NVLinks of a device.
+const NvLinkMaxLinks = 18
+
+// NvLinkErrorCounter is an NVLink error counter.
+type NvLinkErrorCounter int
+
+// NVLink error counters.
+const (
+	NvLinkErrorDLReplay NvLinkErrorCounter = iota
+	NvLinkErrorDLRecovery
+	NvLinkErrorDLCRCFlit
+	NvLinkErrorDLCRCData
+)
+
+// VgpuInstance is the handle of a vGPU instance running on the device.
+type VgpuInstance uint
+
+// GridLicensableFeature describes a vGPU software licensable feature.
+type GridLicensableFeature struct {
+	FeatureCode uint
+	Licensed    bool
+	Enabled     bool
+	ProductName string
+	// LicenseExpiry is zero if the driver doesn't report the expiry.
+	LicenseExpiry time.Time
+}
+
+// PerfPolicyType is a reason for which the clocks of the device are reduced.
+type PerfPolicyType uint
+
+// Performance policies.
+const (
+	PerfPolicyPower   PerfPolicyType = 0
+	PerfPolicyThermal PerfPolicyType = 1
+)
+
+// MemoryInfoV2 holds the memory of the device in bytes, including the
+// memory reserved by the driver and firmware.
+type MemoryInfoV2 struct {
+	Total    uint64
+	Reserved uint64
+	Free     uint64
+	Used     uint64
+}
+
+// ValueType is the type of the value of a FieldValue.
+type ValueType uint
+
+// Value types.
+const (
+	ValueTypeDouble           ValueType = 0
+	ValueTypeUnsignedInt      ValueType = 1
+	ValueTypeUnsignedLong     ValueType = 2
+	ValueTypeUnsignedLongLong ValueType = 3
+	ValueTypeSignedLongLong   ValueType = 4
+	ValueTypeSignedInt        ValueType = 5
+)
+
+// FieldDevGetGpuRecoveryAction is the field holding the action needed to
+// recover the device.
+const FieldDevGetGpuRecoveryAction uint = 230
+
+// The fields read by the methods of Device.
+const (
+	fieldMemoryTemp             uint = 82
+	fieldNvLinkThroughputDataTx uint = 138
+	fieldNvLinkThroughputDataRx uint = 139
+	fieldC2CLinkCount           uint = 170
+	fieldC2CLinkGetStatus       uint = 171
+	fieldC2CLinkGetMaxBW        uint = 172
+)
+
+// FieldValue is a value returned by FieldValues.
+type FieldValue struct {
+	FieldID uint
+	ScopeID uint
+	// Timestamp is the CPU timestamp of the value in microseconds.
+	Timestamp int64
+	ValueType ValueType
+	// Value holds the raw value in host byte order; it is to be read
+	// according to ValueType.
+	Value [8]byte
+	// Err is set if the field could not be read.
+	Err error
+}
+
+// GpmMetricID is a GPM metric.
+type GpmMetricID uint
+
+// GPM metrics.
+const (
+	GpmMetricSMUtil        GpmMetricID = 2
+	GpmMetricSMOccupancy   GpmMetricID = 3
+	GpmMetricAnyTensorUtil GpmMetricID = 5
+	GpmMetricDRAMBWUtil    GpmMetricID = 10
+)
+
+// GpmMetric is a GPM metric computed from two samples.
+type GpmMetric struct {
+	MetricID GpmMetricID
+	Value    float64
+	// Err is set if the metric could not be computed.
+	Err error
+}
+
+// UnitInfo holds the static information of an S-class unit.
+type UnitInfo struct {
+	Name            string
+	ID              string
+	Serial          string
+	FirmwareVersion string
+}
+
+// PSUInfo holds the state of the power supply of an S-class unit.
+type PSUInfo struct {
+	State string
+	// Current is in A, Voltage in V and Power in W.
+	Current uint
+	Voltage uint
+	Power   uint
+}
+
+// UnitFanInfo holds the state of a fan of an S-class unit.
+type UnitFanInfo struct {
+	// Speed is in RPM.
+	Speed  uint
+	Failed bool
+}
+
+// UnitTemperatureType is a temperature sensor of an S-class unit.
+type UnitTemperatureType uint
+
+// Unit temperature sensors.
+const (
+	UnitTemperatureIntake  UnitTemperatureType = 0
+	UnitTemperatureExhaust UnitTemperatureType = 1
+	UnitTemperatureBoard   UnitTemperatureType = 2
+)
+
+// ExcludedDeviceInfo identifies a device excluded from use by NVML.
+type ExcludedDeviceInfo struct {
+	PCIBusID string
+	UUID     string
+}
+
+// GpuFabricInfo holds the state of the device in the NVLink fabric.
+type GpuFabricInfo struct {
+	ClusterUUID string
+	CliqueID    uint
+	State       uint
+	// Status is the error of the registration, if any. It's only meaningful
+	// once the registration is complete.
+	Status error
+}
+
+// ConfComputeState holds the confidential computing state of the system.
+type ConfComputeState struct {
+	Environment  uint
+	CCFeature    bool
+	DevToolsMode bool
+}
+
+// SamplingType is a buffer of samples kept by the driver.
+type SamplingType uint
+
+// Sampling types.
+const (
+	SamplingTypeTotalPower     SamplingType = 0
+	SamplingTypeGPUUtilization SamplingType = 1
+)
+
+// Sample is a sample from one of the buffers kept by the driver.
+type Sample struct {
+	// TimeStamp is the CPU timestamp of the sample in microseconds.
+	TimeStamp uint64
+	Value     uint64
+}
+
+// ClockType is a clock domain of the device.
+type ClockType uint
+
+// Clock domains.
+const (
+	ClockGraphics ClockType = 0
+	ClockSM       ClockType = 1
+	ClockMem      ClockType = 2
+)
+
+// InfoROM objects.
+const (
+	InforomOEM   = 0
+	InforomECC   = 1
+	InforomPower = 2
+)
+
+// ClockOffset holds the offset of a clock in a performance state and its
+// allowed range, in MHz.
+type ClockOffset struct {
+	Offset int
+	Min    int
+	Max    int
+}
+
+// ThermalMaxSensors is the maximum number of thermal sensors of a device.
+const ThermalMaxSensors = 3
+
+// ThermalSettings holds the thermal sensors of a device. Only the first Count
+// of Sensors are set.
+type ThermalSettings struct {
+	Count   uint
+	Sensors [ThermalMaxSensors]ThermalSensor
+}
+
+// ThermalSensor holds the settings and temperature of a thermal sensor, in
+// Celsius.
+type ThermalSensor struct {
+	Controller     int
+	DefaultMinTemp int
+	DefaultMaxTemp int
+	CurrentTemp    int
+	Target         uint
+}
+
+// Return is an NVML return code (nvmlReturn_t).
+type Return int
+
+// The NVML return codes other than NVML_SUCCESS.
+const (
+	ErrorUninitialized           Return = 1
+	ErrorInvalidArgument         Return = 2
+	ErrorNotSupported            Return = 3
+	ErrorNoPermission            Return = 4
+	ErrorAlreadyInitialized      Return = 5
+	ErrorNotFound                Return = 6
+	ErrorInsufficientSize        Return = 7
+	ErrorInsufficientPower       Return = 8
+	ErrorDriverNotLoaded         Return = 9
+	ErrorTimeout                 Return = 10
+	ErrorIRQIssue                Return = 11
+	ErrorLibraryNotFound         Return = 12
+	ErrorFunctionNotFound        Return = 13
+	ErrorCorruptedInforom        Return = 14
+	ErrorGPUIsLost               Return = 15
+	ErrorResetRequired           Return = 16
+	ErrorOperatingSystem         Return = 17
+	ErrorLibRMVersionMismatch    Return = 18
+	ErrorInUse                   Return = 19
+	ErrorMemory                  Return = 20
+	ErrorNoData                  Return = 21
+	ErrorVgpuEccNotSupported     Return = 22
+	ErrorInsufficientResources   Return = 23
+	ErrorFreqNotSupported        Return = 24
+	ErrorArgumentVersionMismatch Return = 25
+	ErrorDeprecated              Return = 26
+	ErrorNotReady                Return = 27
+	ErrorGPUNotFound             Return = 28
+	ErrorInvalidState            Return = 29
+	ErrorResetTypeNotSupported   Return = 30
+	ErrorUnknown                 Return = 999
+)
+
+// Error is the error of an NVML function that didn't return NVML_SUCCESS.
+type Error struct {
+	Return Return
+	// Message is the description of Return by nvmlErrorString.
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "nvml: " + e.Message
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+	"github.com/xofym/gonvml"
+)
+
+// gpmMetrics are the GPM metrics that are exported, by NVML metric ID. GPM
+// reports them as percentages.
+var gpmMetrics = []gonvml.GpmMetricID{
+	gonvml.GpmMetricSMUtil,
+	gonvml.GpmMetricSMOccupancy,
+	gonvml.GpmMetricAnyTensorUtil,
+	gonvml.GpmMetricDRAMBWUtil,
+}
+
+// gpmGauge returns the gauge of the GPM metric with the given ID.
+func (c *Collector) gpmGauge(id gonvml.GpmMetricID) *prometheus.GaugeVec {
+	switch id {
+	case gonvml.GpmMetricSMUtil:
+		return c.smActive
+	case gonvml.GpmMetricSMOccupancy:
+		return c.smOccupancy
+	case gonvml.GpmMetricAnyTensorUtil:
+		return c.tensorActive
+	case gonvml.GpmMetricDRAMBWUtil:
+		return c.dramActive
+	}
+	return nil
+}
+
+// collectGpm reads the GPM metrics of the device. GPM metrics are computed
+// from two samples, so a sample is taken on every update and the metrics
+// cover the time since the previous update. Nothing is exported on the first
+// update of a device. The caller must hold the lock.
+func (c *Collector) collectGpm(dev nvmlDevice, i int, minor, uuid, name string) {
+	sample, err := c.nvml.GpmSampleAlloc()
+	if err != nil {
+		c.queryFailed(err, i, "GpmSampleAlloc")
+		return
+	}
+	if err := dev.GpmSampleGet(sample); err != nil {
+		c.queryFailed(err, i, "GpmSampleGet")
+		freeGpmSample(sample)
+		return
+	}
+
+	previous, ok := c.gpmSamples[uuid]
+	c.gpmSamples[uuid] = sample
+	if !ok {
+		return
+	}
+	defer freeGpmSample(previous)
+
+	metrics, err := c.nvml.GpmMetricsGet(previous, sample, gpmMetrics)
+	if err != nil {
+		c.queryFailed(err, i, "GpmMetricsGet")
+		return
+	}
+	for _, m := range metrics {
+		if m.Err != nil {
+			log.Debug().
+				Err(m.Err).
+				Int("device_index", i).
+				Uint("metric_id", uint(m.MetricID)).
+				Msg("Cannot get GPM metric")
+			c.countError(m.Err)
+			continue
+		}
+		if gauge := c.gpmGauge(m.MetricID); gauge != nil {
+			gauge.WithLabelValues(minor, uuid, name).Set(m.Value / 100)
+		}
+	}
+}
+
+// freeGpmSamples frees the samples of the devices not in seen, e.g. because
+// they were removed. All samples are freed if seen is nil. The caller must
+// hold the lock.
+func (c *Collector) freeGpmSamples(seen map[string]bool) {
+	for uuid, sample := range c.gpmSamples {
+		if !seen[uuid] {
+			freeGpmSample(sample)
+			delete(c.gpmSamples, uuid)
+		}
+	}
+}
+
+func freeGpmSample(sample gonvml.GpmSample) {
+	if err := sample.Free(); err != nil {
+		log.Warn().
+			Err(err).
+			Msg("Cannot free GPM sample")
+	}
+}
@@ -0,0 +1,70 @@
+//go:build linux && cgo
+// +build linux,cgo
+
+package main
+
+/*
+#define _GNU_SOURCE
+#include <errno.h>
+#include <fcntl.h>
+#include <sched.h>
+#include <stdlib.h>
+#include <unistd.h>
+
+// hostNamespaceErrno is the errno of the failed call if the exporter could
+// not join the host's mount namespace.
+static int hostNamespaceErrno;
+
+// joinHostNamespace joins the mount namespace of the host's init process in
+// the exporter re-executed by enterHostNamespace. setns(2) refuses to change
+// the mount namespace of a multi-threaded process, so this runs as a
+// constructor, before the Go runtime starts its threads.
+__attribute__((constructor)) static void joinHostNamespace(void) {
+	if (getenv("NVIDIA_GPU_EXPORTER_HOST_NAMESPACE") == NULL) {
+		return;
+	}
+	int fd = open("/proc/1/ns/mnt", O_RDONLY | O_CLOEXEC);
+	if (fd < 0) {
+		hostNamespaceErrno = errno;
+		return;
+	}
+	if (setns(fd, CLONE_NEWNS) < 0) {
+		hostNamespaceErrno = errno;
+	}
+	close(fd);
+}
+
+static int hostNamespaceError(void) {
+	return hostNamespaceErrno;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// hostNamespaceEnv is set in the environment of the exporter re-executed by
+// enterHostNamespace. It must match the name used by joinHostNamespace.
+const hostNamespaceEnv = "NVIDIA_GPU_EXPORTER_HOST_NAMESPACE"
+
+// enterHostNamespace makes the exporter run in the mount namespace of the
+// host's init process, so that NVML loads the host's driver and sees all of
+// its devices. The namespace can only be joined before the Go runtime starts,
+// so the exporter re-executes itself and the namespace is joined by
+// joinHostNamespace. enterHostNamespace only returns in the re-executed
+// exporter or on failure.
+func enterHostNamespace() error {
+	if os.Getenv(hostNamespaceEnv) == "" {
+		env := append(os.Environ(), hostNamespaceEnv+"=1")
+		if err := syscall.Exec("/proc/self/exe", os.Args, env); err != nil {
+			return fmt.Errorf("cannot re-execute the exporter: %v", err)
+		}
+	}
+	if errno := C.hostNamespaceError(); errno != 0 {
+		return fmt.Errorf("cannot join the mount namespace of /proc/1: %v", syscall.Errno(errno))
+	}
+	return nil
+}
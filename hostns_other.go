@@ -0,0 +1,12 @@
+//go:build !linux || !cgo
+// +build !linux !cgo
+
+package main
+
+import "errors"
+
+// enterHostNamespace is only supported on Linux with cgo, see
+// hostns_linux.go.
+func enterHostNamespace() error {
+	return errors.New("-nvml.host-namespace is only supported on Linux")
+}
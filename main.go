@@ -2,12 +2,23 @@ package main
 
 import (
 	"flag"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
+	"unicode"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/xofym/gonvml"
@@ -15,214 +26,1620 @@ import (
 
 const (
 	namespace = "nvidia_gpu"
+
+	// queryRetryDelay is the delay before the first retry of a query. It
+	// doubles with every further retry.
+	queryRetryDelay = 10 * time.Millisecond
+)
+
+var (
+	addr        = flag.String("web.listen-address", ":9445", "Address to listen on for web interface and telemetry.")
+	enablePprof = flag.Bool("web.enable-pprof", false, "Serve profiling data under /debug/pprof/.")
+	compression = flag.Bool("web.enable-compression", true, "Compress the metrics with gzip for clients that accept it.")
+	debug       = flag.Bool("log.debug", false, "sets log level to debug")
+	dryRun      = flag.Bool("dry-run", false, "Write the metrics to stdout once and exit instead of serving them.")
+	selfTestRun = flag.Bool("selftest", false, "Run every query against the first device, write which succeed to stdout and exit.")
+
+	readTimeout  = flag.Duration("web.read-timeout", 10*time.Second, "Maximum duration for reading a request, including its body.")
+	writeTimeout = flag.Duration("web.write-timeout", time.Minute, "Maximum duration for writing a response. Has to cover the collection of the metrics on scrapes and CPU profiles.")
+	idleTimeout  = flag.Duration("web.idle-timeout", 2*time.Minute, "Maximum duration to keep an idle keep-alive connection open.")
+
+	hostNamespace = flag.Bool("nvml.host-namespace", false, "Load NVML in the mount namespace of the host's init process, to report all devices of the host from a container. Requires --privileged and --pid=host.")
+
+	otlpEndpoint = flag.String("otlp.endpoint", "", "URL of an OTLP/HTTP metrics endpoint, e.g. http://localhost:4318/v1/metrics, to push the metrics to every -collect.interval in addition to serving them.")
+
+	compatDutyCycle      = flag.Bool("metrics.compat-duty-cycle", true, "Also export nvidia_gpu_duty_cycle, which is deprecated in favor of nvidia_gpu_utilization_percent.")
+	legacyPower          = flag.Bool("metrics.legacy-power", true, "Also export nvidia_gpu_power_usage_milliwatts, which is deprecated in favor of nvidia_gpu_power_usage_watts.")
+	unlabeledTemperature = flag.Bool("compat.unlabeled-temperature", true, "Export nvidia_gpu_temperature_celsius without the sensor label and only for the GPU sensor, as older versions did. Set to false to export every sensor with a sensor label.")
+
+	collectInterval   = flag.Duration("collect.interval", 0, "Interval at which metrics are read from the devices in the background. When 0, metrics are read on every scrape.")
+	queryRetries      = flag.Int("collect.query-retries", 2, "Number of times to retry queries that fail with an unknown error.")
+	deviceUp          = flag.Bool("collect.device-up", false, "Export nvidia_gpu_device_up for every device index, 0 for devices that cannot be queried. The minor_number label is the index for those.")
+	utilizationWindow = flag.Duration("collector.utilization.window", 0, "Window over which nvidia_gpu_utilization_average_percent and nvidia_gpu_power_usage_average_milliwatts are averaged. When 0, the time since the device was last read, i.e. the scrape or collection interval.")
+	maxDevices        = flag.Int("collect.max-devices", 0, "Maximum number of devices to collect metrics from. 0 means unlimited.")
+	collectOnDemand   = flag.Bool("collect.on-demand", false, "Only read metrics from the devices on startup and on POST requests to /collect, scrapes return the most recently read values.")
+	nameCache         = flag.Bool("collect.name-cache", false, "Only query the minor number, UUID and name of a device the first time it is seen, until the number of devices changes.")
+	pciBusIDLabel     = flag.Bool("collect.pci-bus-id-label", false, "Add the pci_bus_id label to every metric with a uuid label, to follow the PCIe slot of a device rather than the device itself.")
+	sanitizeNames     = flag.Bool("collect.sanitize-names", false, "Collapse whitespace and strip non-printable characters in the name label.")
+	memoryUnit        = flag.String("collect.memory-unit", "bytes", "Unit of the memory metrics, bytes or mib. With mib, their names end in _mebibytes instead of _bytes.")
+	collectProcesses  = flag.Bool("collect.processes", false, "Collect the memory used by each process running on the devices.")
+	processCmdline    = flag.Bool("collect.process-cmdline", false, "Add the command name of each process from /proc/<pid>/comm as the command label of nvidia_gpu_process_memory_used_bytes.")
+	collectVgpus      = flag.Bool("collect.vgpu", false, "Collect metrics of the vGPU instances running on the devices.")
+	collectAccounting = flag.Bool("collect.accounting", false, "Collect per-process accounting statistics. Requires accounting mode to be enabled on the device.")
+	collectUnits      = flag.Bool("collect.units", false, "Collect the PSU, fan and temperature metrics of S-class units, e.g. the chassis of HGX systems.")
+	collectFields     = flag.String("collector.fields", "", "Comma separated list of NVML field IDs to collect, e.g. 1,2.")
+	enableEvents      = flag.Bool("collect.enable-events", false, "Count XID errors and double bit ECC errors as the driver reports them. Registering for events may require additional permissions.")
+	collectGpm        = flag.Bool("collector.gpm", false, "Collect SM, tensor core and DRAM activity using GPM. Only supported on Hopper and newer devices.")
+
+	collectUtilizationHistogram = flag.Bool("collect.utilization-histogram", false, "Observe the utilization samples taken by the driver in the nvidia_gpu_utilization_samples_percent histogram.")
+	utilizationBucketsFlag      = flag.String("collect.utilization-buckets", "10,20,30,40,50,60,70,80,90,100", "Comma separated upper bounds of the buckets of nvidia_gpu_utilization_samples_percent and nvidia_gpu_duty_cycle.")
+	dutyCycleSamples            = flag.Int("collect.duty-cycle-samples", 0, "Number of times to sample the utilization of each device on every read, observed in the nvidia_gpu_duty_cycle histogram instead of the gauge. When 0, nvidia_gpu_duty_cycle is a gauge.")
+	dutyCycleSampleInterval     = flag.Duration("collect.duty-cycle-sample-interval", 100*time.Millisecond, "Interval between the utilization samples of -collect.duty-cycle-samples. Every read takes that much longer per device and sample.")
+
+	labels        = []string{"minor_number", "uuid", "name"}
+	processLabels = []string{"minor_number", "uuid", "name", "pid"}
+	// type is either "compute" or "graphics".
+	processTypeLabels       = []string{"minor_number", "uuid", "name", "pid", "type"}
+	thermalSensorLabels     = []string{"minor_number", "uuid", "name", "index", "sensor", "controller"}
+	clockLabels             = []string{"minor_number", "uuid", "name", "clock"}
+	clockOffsetLabels       = []string{"minor_number", "uuid", "name", "clock", "pstate"}
+	processCommandLabels    = []string{"minor_number", "uuid", "name", "pid", "type", "command"}
+	migModeLabels           = []string{"minor_number", "uuid", "name", "state"}
+	migLabels               = []string{"minor_number", "uuid", "name", "gpu_instance_id", "compute_instance_id", "mig_profile"}
+	migInfoLabels           = []string{"minor_number", "uuid", "name", "gpu_instance_id", "compute_instance_id", "mig_profile", "mig_uuid"}
+	vgpuLabels              = []string{"minor_number", "uuid", "name", "vgpu_instance", "vm_id"}
+	licenseLabels           = []string{"minor_number", "uuid", "name", "product"}
+	recoveryActionLabels    = []string{"minor_number", "uuid", "name", "action"}
+	fieldLabels             = []string{"minor_number", "uuid", "name", "field_id", "field_name"}
+	unitLabels              = []string{"unit"}
+	unitInfoLabels          = []string{"unit", "name", "id", "serial", "firmware_version"}
+	unitPSUStateLabels      = []string{"unit", "state"}
+	unitFanLabels           = []string{"unit", "fan"}
+	unitTemperatureLabels   = []string{"unit", "sensor"}
+	excludedDeviceLabels    = []string{"uuid", "pci_bus_id"}
+	deviceUpLabels          = []string{"minor_number"}
+	fabricStatusLabels      = []string{"minor_number", "uuid", "name", "status"}
+	fabricInfoLabels        = []string{"minor_number", "uuid", "name", "cluster_uuid", "clique_id"}
+	temperatureLabels       = []string{"minor_number", "uuid", "name", "sensor"}
+	inforomLabels           = []string{"minor_number", "uuid", "name", "oem_version", "ecc_version", "power_version", "image_version"}
+	querySupportedLabels    = []string{"minor_number", "uuid", "name", "query"}
+	operatingModeLabels     = []string{"minor_number", "uuid", "name", "mode"}
+	driverModelLabels       = []string{"minor_number", "uuid", "name", "model"}
+	nvlinkLabels            = []string{"minor_number", "uuid", "name", "link"}
+	nvlinkRemoteLabels      = []string{"minor_number", "uuid", "name", "link", "remote_pci_bus_id", "remote_type"}
+	nvlinkErrorLabels       = []string{"minor_number", "uuid", "name", "link", "type"}
+	xidLabels               = []string{"minor_number", "uuid", "name", "xid"}
+	computeCapabilityLabels = []string{"minor_number", "uuid", "name", "major", "minor"}
+	infoLabels              = []string{"minor_number", "uuid", "name", "serial", "vbios_version", "pci_bus_id", "board_part_number", "brand", "architecture", "board_id", "irq", "pci_device_id", "pci_sub_system_id"}
 )
 
-var (
-	addr  = flag.String("web.listen-address", ":9445", "Address to listen on for web interface and telemetry.")
-	debug = flag.Bool("log.debug", false, "sets log level to debug")
+type Collector struct {
+	sync.Mutex
+	nvml nvmlLibrary
+
+	startTime   *prometheus.GaugeVec
+	scrapes     *prometheus.CounterVec
+	lastSuccess *prometheus.GaugeVec
+
+	// Sums of the per-device values set in the same update.
+	usedMemorySum  *prometheus.GaugeVec
+	totalMemorySum *prometheus.GaugeVec
+	powerUsageSum  *prometheus.GaugeVec
+
+	numDevices  *prometheus.GaugeVec
+	usedMemory  *prometheus.GaugeVec
+	totalMemory *prometheus.GaugeVec
+	dutyCycle   *prometheus.GaugeVec
+	powerUsage  *prometheus.GaugeVec
+	temperature *prometheus.GaugeVec
+	fanSpeed    *prometheus.GaugeVec
+
+	powerUsageWatts *prometheus.GaugeVec
+
+	utilization       *prometheus.GaugeVec
+	memoryUtilization *prometheus.GaugeVec
+
+	utilizationSampleAge *prometheus.GaugeVec
+	utilizationPeriod    *prometheus.GaugeVec
+	utilizationHistogram *prometheus.HistogramVec
+	dutyCycleHistogram   *prometheus.HistogramVec
+	utilizationAverage   *prometheus.GaugeVec
+	powerUsageAverage    *prometheus.GaugeVec
+	powerUsageMax        *prometheus.GaugeVec
+
+	deviceUp *prometheus.GaugeVec
+
+	reservedMemory *prometheus.GaugeVec
+
+	driverInfo        *prometheus.GaugeVec
+	cudaDriverVersion *prometheus.GaugeVec
+
+	confComputeEnabled  *prometheus.GaugeVec
+	confComputeDevTools *prometheus.GaugeVec
+	confComputeReady    *prometheus.GaugeVec
+
+	deviceInfo *prometheus.GaugeVec
+
+	excludedDevices    *prometheus.GaugeVec
+	excludedDeviceInfo *prometheus.GaugeVec
+
+	powerLimitMin *prometheus.GaugeVec
+	powerLimitMax *prometheus.GaugeVec
+
+	powerLimitDefault  *prometheus.GaugeVec
+	powerLimitEnforced *prometheus.GaugeVec
+
+	applicationsClockIsDefault *prometheus.GaugeVec
+
+	powerState  *prometheus.GaugeVec
+	powerSource *prometheus.GaugeVec
+
+	memoryTemperature   *prometheus.GaugeVec
+	temperatureHeadroom *prometheus.GaugeVec
+
+	multiGPUBoard *prometheus.GaugeVec
+	boardID       *prometheus.GaugeVec
+
+	querySupported *prometheus.GaugeVec
+
+	inforomInfo        *prometheus.GaugeVec
+	inforomConfigValid *prometheus.GaugeVec
+
+	computeCapability *prometheus.GaugeVec
+	multiprocessors   *prometheus.GaugeVec
+	cudaCores         *prometheus.GaugeVec
+	numaNode          *prometheus.GaugeVec
+
+	virtualizationMode *prometheus.GaugeVec
+	hostVgpuMode       *prometheus.GaugeVec
+	operatingMode      *prometheus.GaugeVec
+	driverModelCurrent *prometheus.GaugeVec
+	driverModelPending *prometheus.GaugeVec
+
+	eccModeEnabled        *prometheus.GaugeVec
+	eccModePendingEnabled *prometheus.GaugeVec
+
+	remappedRowsCorrectable   *prometheus.GaugeVec
+	remappedRowsUncorrectable *prometheus.GaugeVec
+	remappedRowsPending       *prometheus.GaugeVec
+	remappedRowsFailure       *prometheus.GaugeVec
+
+	resetRequired  *prometheus.GaugeVec
+	recoveryAction *prometheus.GaugeVec
+
+	gspFirmwareEnabled        *prometheus.GaugeVec
+	gspFirmwareDefaultEnabled *prometheus.GaugeVec
+
+	migModeCurrent *prometheus.GaugeVec
+	migModePending *prometheus.GaugeVec
+	migModeEnabled *prometheus.GaugeVec
+	migUsedMemory  *prometheus.GaugeVec
+	migTotalMemory *prometheus.GaugeVec
+	migInfo        *prometheus.GaugeVec
+	migUtilization *prometheus.GaugeVec
+
+	displayActive *prometheus.GaugeVec
+	displayMode   *prometheus.GaugeVec
+
+	attachedDisplays *prometheus.GaugeVec
+
+	thermalSensorTarget     *prometheus.GaugeVec
+	thermalSensorDefaultMin *prometheus.GaugeVec
+	thermalSensorDefaultMax *prometheus.GaugeVec
+
+	clockOffset           *prometheus.GaugeVec
+	clockMaxCustomerBoost *prometheus.GaugeVec
+
+	encoderSessions       *prometheus.GaugeVec
+	encoderAverageLatency *prometheus.GaugeVec
+
+	clockThrottleThermal *prometheus.CounterVec
+	clockThrottlePower   *prometheus.CounterVec
+
+	nvlinkActive *prometheus.GaugeVec
+	nvlinkRemote *prometheus.GaugeVec
+	nvlinkTx     *prometheus.CounterVec
+	nvlinkRx     *prometheus.CounterVec
+	nvlinkErrors *prometheus.CounterVec
+
+	fabricState  *prometheus.GaugeVec
+	fabricStatus *prometheus.GaugeVec
+	fabricInfo   *prometheus.GaugeVec
+
+	c2cLinks        *prometheus.GaugeVec
+	c2cActive       *prometheus.GaugeVec
+	c2cMaxBandwidth *prometheus.GaugeVec
+
+	processCount  *prometheus.GaugeVec
+	processMemory *prometheus.GaugeVec
+
+	vgpuInstances  *prometheus.GaugeVec
+	vgpuUsedMemory *prometheus.GaugeVec
+	licenseStatus  *prometheus.GaugeVec
+	licenseExpiry  *prometheus.GaugeVec
+
+	nvmlErrors       *prometheus.CounterVec
+	permissionDenied *prometheus.GaugeVec
+	queryRetries     *prometheus.CounterVec
+
+	// Counted by watchEvents, never reset.
+	xidErrors    *prometheus.CounterVec
+	eccDBEEvents *prometheus.CounterVec
+
+	fieldValue *prometheus.GaugeVec
+
+	unitInfo        *prometheus.GaugeVec
+	unitPSUState    *prometheus.GaugeVec
+	unitPSUCurrent  *prometheus.GaugeVec
+	unitPSUVoltage  *prometheus.GaugeVec
+	unitPSUPower    *prometheus.GaugeVec
+	unitFanSpeed    *prometheus.GaugeVec
+	unitFanFailed   *prometheus.GaugeVec
+	unitTemperature *prometheus.GaugeVec
+
+	smActive     *prometheus.GaugeVec
+	smOccupancy  *prometheus.GaugeVec
+	tensorActive *prometheus.GaugeVec
+	dramActive   *prometheus.GaugeVec
+
+	accountingEnabled           *prometheus.GaugeVec
+	accountingBufferSize        *prometheus.GaugeVec
+	accountingMaxMemory         *prometheus.GaugeVec
+	accountingGPUUtilization    *prometheus.GaugeVec
+	accountingMemoryUtilization *prometheus.GaugeVec
+	accountingTime              *prometheus.GaugeVec
+
+	// All metric vectors, those reset on every update and those that are not,
+	// see metrics.
+	vecs       []vec
+	resettable []*prometheus.GaugeVec
+	kept       []vec
+
+	// Static properties of the devices by UUID.
+	static map[string]*staticInfo
+
+	// Number of units, counted once by collectUnits.
+	unitCount   uint
+	unitCountOK bool
+
+	// Last GPM samples of the devices by UUID, see collectGpm.
+	gpmSamples map[string]gonvml.GpmSample
+
+	// Time the devices were last read by UUID, see averageWindow.
+	readTimes map[string]time.Time
+
+	// Timestamps of the most recent utilization samples of the devices by
+	// UUID, see observeUtilization.
+	utilizationSampleTimes map[string]uint64
+
+	// Timestamps of the most recent power samples of the devices by UUID, see
+	// maxPowerUsage.
+	powerSampleTimes map[string]uint64
+
+	// Last values read from cumulative device counters, see addDelta.
+	counterValues map[string]uint64
+
+	// Queries that failed due to insufficient permissions, see queryFailed.
+	deniedQueries map[string]bool
+
+	// Standard label values by device index, see deviceLabels, and the number
+	// of devices they were cached for.
+	labelCache           map[int]cachedLabels
+	labelCacheNumDevices uint
+
+	// UUIDs of the devices seen by the last update, see forgetDevices.
+	devices map[string]bool
+
+	// Set once confidential computing turned out to be unsupported, see
+	// collectConfCompute.
+	confComputeUnsupported bool
+
+	// Set once the driver turned out not to export the clock offset
+	// function, see collectClockOffsets.
+	clockOffsetsUnsupported bool
+
+	// Set once C2C links turned out to be unsupported, see c2cLinkCount.
+	c2cUnsupported bool
+}
+
+// cachedLabels are the values of the standard labels of a device.
+type cachedLabels struct {
+	minor string
+	uuid  string
+	name  string
+}
+
+func NewCollector() *Collector {
+	c := &Collector{
+		nvml:                   gonvmlLibrary{},
+		static:                 make(map[string]*staticInfo),
+		gpmSamples:             make(map[string]gonvml.GpmSample),
+		readTimes:              make(map[string]time.Time),
+		powerSampleTimes:       make(map[string]uint64),
+		utilizationSampleTimes: make(map[string]uint64),
+		counterValues:          make(map[string]uint64),
+		deniedQueries:          make(map[string]bool),
+		labelCache:             make(map[int]cachedLabels),
+	}
+	for _, m := range c.metrics() {
+		if m.memory {
+			m.name, m.help = memoryUnitName(m.name, m.help)
+		}
+		switch {
+		case m.gauge != nil:
+			*m.gauge = prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Namespace: namespace,
+					Name:      m.name,
+					Help:      m.help,
+				},
+				m.labels,
+			)
+			c.vecs = append(c.vecs, *m.gauge)
+			if m.keep {
+				c.kept = append(c.kept, *m.gauge)
+			} else {
+				c.resettable = append(c.resettable, *m.gauge)
+			}
+		case m.counter != nil:
+			*m.counter = prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: namespace,
+					Name:      m.name,
+					Help:      m.help,
+				},
+				m.labels,
+			)
+			c.vecs = append(c.vecs, *m.counter)
+			c.kept = append(c.kept, *m.counter)
+		case m.histogram != nil:
+			*m.histogram = prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace: namespace,
+					Name:      m.name,
+					Help:      m.help,
+					Buckets:   m.buckets,
+				},
+				m.labels,
+			)
+			c.vecs = append(c.vecs, *m.histogram)
+			c.kept = append(c.kept, *m.histogram)
+		}
+		if *pciBusIDLabel && needsBusID(m.labels) {
+			last := len(c.vecs) - 1
+			c.vecs[last] = newBusIDVec(c, c.vecs[last], m)
+		}
+	}
+	c.startTime.WithLabelValues().Set(float64(time.Now().Unix()))
+	// Exported as 0 until the devices are enumerated.
+	c.lastSuccess.WithLabelValues()
+	return c
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, v := range c.vecs {
+		v.Describe(ch)
+	}
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	// Only one Collect call in progress at a time.
+	c.Lock()
+	defer c.Unlock()
+
+	if readOnScrape() {
+		c.update()
+	}
+
+	c.scrapes.WithLabelValues().Inc()
+
+	for _, v := range c.vecs {
+		v.Collect(ch)
+	}
+}
+
+// poll reads the metrics from the devices every interval, independently of
+// scrapes.
+func (c *Collector) poll(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.refresh()
+		<-ticker.C
+	}
+}
+
+// handleCollect reads the metrics from the devices on POST requests, so that
+// the following scrapes return fresh values.
+func (c *Collector) handleCollect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.revalidateInforoms()
+	c.refresh()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// readOnScrape reports whether Collect reads the metrics from the devices.
+// Otherwise it returns the values most recently read by refresh.
+func readOnScrape() bool {
+	return *collectInterval <= 0 && !*collectOnDemand
+}
+
+// refresh reads the metrics from the devices.
+func (c *Collector) refresh() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.update()
+}
+
+// update reads the current values from the devices into the metric vectors.
+// The caller must hold the lock.
+func (c *Collector) update() {
+	for _, v := range c.resettable {
+		v.Reset()
+	}
+
+	// Read on every update so that driver upgrades show up without a restart.
+	driverVersion, err := c.nvml.SystemDriverVersion()
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Cannot get SystemDriverVersion")
+		c.countError(err)
+	}
+	nvmlVersion, err := c.nvml.SystemNVMLVersion()
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Cannot get SystemNVMLVersion")
+		c.countError(err)
+	}
+	if driverVersion != "" || nvmlVersion != "" {
+		c.driverInfo.WithLabelValues(driverVersion, nvmlVersion).Set(1)
+	}
+
+	cudaDriverVersion, err := c.nvml.SystemCudaDriverVersion()
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Cannot get SystemCudaDriverVersion")
+		c.countError(err)
+	} else {
+		c.cudaDriverVersion.WithLabelValues().Set(float64(cudaDriverVersion))
+	}
+
+	c.collectConfCompute()
+
+	if *collectUnits {
+		c.collectUnits()
+	}
+
+	// Devices excluded by the driver, e.g. after too many retired pages, don't
+	// count towards DeviceCount.
+	excludedDevices, err := c.nvml.ExcludedDeviceCount()
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Cannot get ExcludedDeviceCount")
+		c.countError(err)
+	} else {
+		c.excludedDevices.WithLabelValues().Set(float64(excludedDevices))
+	}
+	for j := uint(0); j < excludedDevices; j++ {
+		excluded, err := c.nvml.ExcludedDeviceInfoByIndex(j)
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Uint("excluded_index", j).
+				Msg("Cannot get ExcludedDeviceInfoByIndex")
+			c.countError(err)
+			continue
+		}
+		c.excludedDeviceInfo.WithLabelValues(excluded.UUID, excluded.PCIBusID).Set(1)
+	}
+
+	numDevices, err := c.nvml.DeviceCount()
+	if err != nil {
+		log.Error().Err(err).Msg("Cannot get DeviceCount")
+		c.countError(err)
+		return
+	} else {
+		c.numDevices.WithLabelValues().Set(float64(numDevices))
+		c.lastSuccess.WithLabelValues().SetToCurrentTime()
+	}
+
+	if numDevices != c.labelCacheNumDevices {
+		c.labelCache = make(map[int]cachedLabels)
+		c.labelCacheNumDevices = numDevices
+	}
+
+	if *maxDevices > 0 && int(numDevices) > *maxDevices {
+		log.Warn().
+			Uint("num_devices", numDevices).
+			Int("max_devices", *maxDevices).
+			Msg("Too many devices, only collecting from the first max_devices")
+		numDevices = uint(*maxDevices)
+	}
+
+	var usedMemorySum, totalMemorySum, powerUsageSum float64
+	seen := make(map[string]bool)
+	for i := 0; i < int(numDevices); i++ {
+		// Device information
+		dev, err := c.nvml.DeviceHandleByIndex(uint(i))
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Int("device_index", i).
+				Msg("Cannot get DeviceHandleByIndex")
+			c.countError(err)
+			c.setDeviceUp(i, "", false)
+			continue
+		}
+
+		minor, uuid, name, ok := c.deviceLabels(dev, i)
+		if !ok {
+			c.setDeviceUp(i, "", false)
+			continue
+		}
+		seen[uuid] = true
+		c.setDeviceUp(i, minor, true)
+		info := c.staticInfo(dev, i, uuid)
+
+		// Metrics
+		// Only newer drivers report the reserved memory, which the basic query
+		// counts as used. It's added back to the used memory so that
+		// nvidia_gpu_memory_used_bytes means the same with both queries.
+		memory, err := dev.MemoryInfoV2()
+		if err == nil {
+			usedMemory := memory.Used + memory.Reserved
+			c.usedMemory.WithLabelValues(minor, uuid, name).Set(memoryValue(float64(usedMemory)))
+			c.totalMemory.WithLabelValues(minor, uuid, name).Set(memoryValue(float64(memory.Total)))
+			c.reservedMemory.WithLabelValues(minor, uuid, name).Set(memoryValue(float64(memory.Reserved)))
+			usedMemorySum += float64(usedMemory)
+			totalMemorySum += float64(memory.Total)
+		} else {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Msg("Cannot get MemoryInfoV2, falling back to MemoryInfo")
+
+			totalMemory, usedMemory, err := dev.MemoryInfo()
+			if err != nil {
+				c.queryFailed(err, i, "MemoryInfo")
+			} else {
+				c.usedMemory.WithLabelValues(minor, uuid, name).Set(memoryValue(float64(usedMemory)))
+				c.totalMemory.WithLabelValues(minor, uuid, name).Set(memoryValue(float64(totalMemory)))
+				usedMemorySum += float64(usedMemory)
+				totalMemorySum += float64(totalMemory)
+			}
+		}
+
+		var dutyCycle, memoryUtilization uint
+		err = c.retry("UtilizationRates", func() (err error) {
+			dutyCycle, memoryUtilization, err = dev.UtilizationRates()
+			return err
+		})
+		if err != nil {
+			c.queryFailed(err, i, "UtilizationRates")
+		} else {
+			c.utilization.WithLabelValues(minor, uuid, name).Set(float64(dutyCycle))
+			c.memoryUtilization.WithLabelValues(minor, uuid, name).Set(float64(memoryUtilization))
+			if *dutyCycleSamples > 0 {
+				c.sampleDutyCycle(dev, i, minor, uuid, name, dutyCycle)
+			} else if *compatDutyCycle {
+				c.dutyCycle.WithLabelValues(minor, uuid, name).Set(float64(dutyCycle))
+			}
+		}
+
+		window, windowOK := c.averageWindow(uuid)
+		if *collectUtilizationHistogram {
+			if err := c.observeUtilization(dev, minor, uuid, name); err != nil {
+				c.queryFailed(err, i, "Samples")
+			}
+		}
+
+		if windowOK {
+			averageUtilization, err := dev.AverageGPUUtilization(window)
+			if err != nil {
+				c.queryFailed(err, i, "AverageGPUUtilization")
+			} else {
+				c.utilizationAverage.WithLabelValues(minor, uuid, name).Set(float64(averageUtilization))
+			}
+		}
+
+		// Sample timestamps are in microseconds since the epoch. NVML reports
+		// Not Found when no process ran since the timestamp, which isn't a
+		// failure.
+		samples, err := dev.ProcessUtilization(0)
+		if isNVMLError(err, gonvml.ErrorNotFound) || (err == nil && len(samples) == 0) {
+			log.Debug().
+				Int("device_index", i).
+				Msg("No ProcessUtilization samples")
+		} else if err != nil {
+			c.queryFailed(err, i, "ProcessUtilization")
+		} else {
+			var latest uint64
+			for _, sample := range samples {
+				if sample.TimeStamp > latest {
+					latest = sample.TimeStamp
+				}
+			}
+			age := time.Since(time.Unix(0, int64(latest)*int64(time.Microsecond)))
+			c.utilizationSampleAge.WithLabelValues(minor, uuid, name).Set(age.Seconds())
+		}
+
+		period, ok, err := utilizationSamplePeriod(dev)
+		if err != nil {
+			c.queryFailed(err, i, "Samples")
+		} else if ok {
+			c.utilizationPeriod.WithLabelValues(minor, uuid, name).Set(period.Seconds())
+		}
+
+		var powerUsage uint
+		err = c.retry("PowerUsage", func() (err error) {
+			powerUsage, err = dev.PowerUsage()
+			return err
+		})
+		if err != nil {
+			c.queryFailed(err, i, "PowerUsage")
+		} else {
+			c.powerUsageWatts.WithLabelValues(minor, uuid, name).Set(float64(powerUsage) / 1000)
+			if *legacyPower {
+				c.powerUsage.WithLabelValues(minor, uuid, name).Set(float64(powerUsage))
+			}
+			powerUsageSum += float64(powerUsage)
+		}
+
+		if windowOK {
+			// Without samples in the window, e.g. because the driver only
+			// just started sampling, fall back to the instantaneous reading
+			// if PowerUsage succeeded.
+			averagePowerUsage, averageErr := dev.AveragePowerUsage(window)
+			if isNVMLError(averageErr, gonvml.ErrorNotFound) && err == nil {
+				averagePowerUsage, averageErr = powerUsage, nil
+			}
+			if averageErr != nil {
+				c.queryFailed(averageErr, i, "AveragePowerUsage")
+			} else {
+				c.powerUsageAverage.WithLabelValues(minor, uuid, name).Set(float64(averagePowerUsage))
+			}
+		}
+
+		maxPowerUsage, ok, err := c.maxPowerUsage(dev, uuid)
+		if err != nil {
+			c.queryFailed(err, i, "Samples")
+		} else if ok {
+			c.powerUsageMax.WithLabelValues(minor, uuid, name).Set(float64(maxPowerUsage))
+		}
+
+		powerLimitMin, powerLimitMax, err := dev.PowerManagementLimitConstraints()
+		if err != nil {
+			c.queryFailed(err, i, "PowerManagementLimitConstraints")
+		} else {
+			c.powerLimitMin.WithLabelValues(minor, uuid, name).Set(float64(powerLimitMin))
+			c.powerLimitMax.WithLabelValues(minor, uuid, name).Set(float64(powerLimitMax))
+		}
+
+		powerLimitDefault, err := dev.PowerManagementDefaultLimit()
+		if err != nil {
+			c.queryFailed(err, i, "PowerManagementDefaultLimit")
+		} else {
+			c.powerLimitDefault.WithLabelValues(minor, uuid, name).Set(float64(powerLimitDefault))
+		}
+
+		powerLimitEnforced, err := dev.EnforcedPowerLimit()
+		if err != nil {
+			c.queryFailed(err, i, "EnforcedPowerLimit")
+		} else {
+			c.powerLimitEnforced.WithLabelValues(minor, uuid, name).Set(float64(powerLimitEnforced))
+		}
+
+		if isDefault, err := applicationsClockIsDefault(dev); err != nil {
+			c.queryFailed(err, i, "ApplicationsClock")
+		} else {
+			c.applicationsClockIsDefault.WithLabelValues(minor, uuid, name).Set(boolToFloat64(isDefault))
+		}
+
+		// 32 is NVML_PSTATE_UNKNOWN.
+		powerState, err := dev.PowerState()
+		if err != nil {
+			c.queryFailed(err, i, "PowerState")
+		} else if powerState != 32 {
+			c.powerState.WithLabelValues(minor, uuid, name).Set(float64(powerState))
+		}
+
+		// Only supported by laptop GPUs.
+		powerSource, err := dev.PowerSource()
+		if err != nil {
+			c.queryFailed(err, i, "PowerSource")
+		} else {
+			c.powerSource.WithLabelValues(minor, uuid, name).Set(float64(powerSource))
+		}
+
+		c.collectDriverModel(dev, i, minor, uuid, name)
+
+		temperature, err := dev.Temperature()
+		if err != nil {
+			c.queryFailed(err, i, "Temperature")
+		} else {
+			c.setTemperature(minor, uuid, name, "gpu", temperature)
+
+			shutdownTemperature, err := dev.TemperatureThreshold(gonvml.TemperatureThresholdShutdown)
+			if err != nil {
+				c.queryFailed(err, i, "TemperatureThreshold")
+			} else {
+				headroom := float64(shutdownTemperature) - float64(temperature)
+				c.temperatureHeadroom.WithLabelValues(minor, uuid, name).Set(headroom)
+			}
+		}
+
+		c.collectThermalSettings(dev, i, minor, uuid, name)
+
+		// Passively cooled devices have no fans.
+		if info.supported["fan_speed"] {
+			fanSpeed, err := dev.FanSpeed()
+			if err != nil {
+				c.queryFailed(err, i, "FanSpeed")
+			} else {
+				c.fanSpeed.WithLabelValues(minor, uuid, name).Set(float64(fanSpeed))
+			}
+		}
+
+		// Only available on devices with a separate memory sensor, e.g. HBM.
+		memoryTemperature, err := dev.MemoryTemperature()
+		if err != nil {
+			c.queryFailed(err, i, "MemoryTemperature")
+		} else {
+			c.memoryTemperature.WithLabelValues(minor, uuid, name).Set(float64(memoryTemperature))
+			c.setTemperature(minor, uuid, name, "memory", memoryTemperature)
+		}
+
+		// Only reported by newer drivers.
+		if !*unlabeledTemperature {
+			hotspotTemperature, err := dev.HotspotTemperature()
+			if err != nil {
+				c.queryFailed(err, i, "HotspotTemperature")
+			} else {
+				c.setTemperature(minor, uuid, name, "hotspot", hotspotTemperature)
+			}
+		}
+
+		// Violation times are cumulative and in nanoseconds.
+		_, thermalViolation, err := dev.ViolationStatus(gonvml.PerfPolicyThermal)
+		if err != nil {
+			c.queryFailed(err, i, "ViolationStatus")
+		} else {
+			c.addDelta(c.clockThrottleThermal.WithLabelValues(minor, uuid, name), uuid+"/clock_throttle_thermal", thermalViolation/1000)
+		}
+		_, powerViolation, err := dev.ViolationStatus(gonvml.PerfPolicyPower)
+		if err != nil {
+			c.queryFailed(err, i, "ViolationStatus")
+		} else {
+			c.addDelta(c.clockThrottlePower.WithLabelValues(minor, uuid, name), uuid+"/clock_throttle_power", powerViolation/1000)
+		}
+
+		var boardID string
+		if info.boardIDOK {
+			boardID = strconv.FormatUint(uint64(info.boardID), 10)
+		}
+		c.deviceInfo.WithLabelValues(minor, uuid, name, info.serial, info.vbiosVersion, info.pciBusID, info.boardPartNumber, info.brand, info.architecture, boardID, info.irq, info.pciDeviceID, info.pciSubsystemID).Set(1)
+		if info.inforomImageVersion != "" {
+			c.inforomInfo.WithLabelValues(minor, uuid, name, info.inforomOEMVersion, info.inforomECCVersion, info.inforomPowerVersion, info.inforomImageVersion).Set(1)
+		}
+		c.validateInforom(dev, i, info)
+		if info.inforomValidOK {
+			c.inforomConfigValid.WithLabelValues(minor, uuid, name).Set(boolToFloat64(info.inforomValid))
+		}
+		for clock, mhz := range info.maxCustomerBoostClocks {
+			c.clockMaxCustomerBoost.WithLabelValues(minor, uuid, name, clock).Set(float64(mhz) * 1e6)
+		}
+		for query, supported := range info.supported {
+			c.querySupported.WithLabelValues(minor, uuid, name, query).Set(boolToFloat64(supported))
+		}
+		if info.multiGPUBoardOK {
+			c.multiGPUBoard.WithLabelValues(minor, uuid, name).Set(boolToFloat64(info.multiGPUBoard))
+		}
+		if info.boardIDOK {
+			c.boardID.WithLabelValues(minor, uuid, name).Set(float64(info.boardID))
+		}
+		if info.computeCapabilityOK {
+			major := strconv.Itoa(info.computeCapabilityMajor)
+			minorVersion := strconv.Itoa(info.computeCapabilityMinor)
+			c.computeCapability.WithLabelValues(minor, uuid, name, major, minorVersion).Set(1)
+		}
+		if info.multiprocessorsOK {
+			c.multiprocessors.WithLabelValues(minor, uuid, name).Set(float64(info.multiprocessors))
+		}
+		if info.cudaCoresOK {
+			c.cudaCores.WithLabelValues(minor, uuid, name).Set(float64(info.cudaCores))
+		}
+		if info.numaNodeOK {
+			c.numaNode.WithLabelValues(minor, uuid, name).Set(float64(info.numaNode))
+		}
+		for _, r := range info.nvlinkRemotes {
+			c.nvlinkRemote.WithLabelValues(minor, uuid, name, r.link, r.pciBusID, r.deviceType).Set(1)
+		}
+
+		virtualizationMode, err := dev.VirtualizationMode()
+		if err != nil {
+			c.queryFailed(err, i, "VirtualizationMode")
+		} else {
+			c.virtualizationMode.WithLabelValues(minor, uuid, name).Set(float64(virtualizationMode))
+		}
+
+		// Only supported by devices that can host vGPUs.
+		hostVgpuMode, err := dev.HostVgpuMode()
+		if err != nil {
+			c.queryFailed(err, i, "HostVgpuMode")
+		} else {
+			c.hostVgpuMode.WithLabelValues(minor, uuid, name).Set(float64(hostVgpuMode))
+		}
+
+		// Not supported by devices without ECC memory.
+		if info.supported["ecc"] {
+			eccCurrent, eccPending, err := dev.EccMode()
+			if err != nil {
+				c.queryFailed(err, i, "EccMode")
+			} else {
+				c.eccModeEnabled.WithLabelValues(minor, uuid, name).Set(boolToFloat64(eccCurrent))
+				c.eccModePendingEnabled.WithLabelValues(minor, uuid, name).Set(boolToFloat64(eccPending))
+			}
+		}
+
+		// Only supported from Ampere on, older devices retire pages instead.
+		corrRows, uncRows, remapPending, remapFailure, err := dev.RemappedRows()
+		if err != nil {
+			c.queryFailed(err, i, "RemappedRows")
+		} else {
+			c.remappedRowsCorrectable.WithLabelValues(minor, uuid, name).Set(float64(corrRows))
+			c.remappedRowsUncorrectable.WithLabelValues(minor, uuid, name).Set(float64(uncRows))
+			c.remappedRowsPending.WithLabelValues(minor, uuid, name).Set(boolToFloat64(remapPending))
+			c.remappedRowsFailure.WithLabelValues(minor, uuid, name).Set(boolToFloat64(remapFailure))
+		}
+
+		c.collectRecoveryAction(dev, i, minor, uuid, name)
+
+		// Drivers that predate GSP firmware don't export the function at all.
+		gspEnabled, gspDefaultEnabled, err := dev.GspFirmwareMode()
+		if isNVMLError(err, gonvml.ErrorFunctionNotFound) {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Msg("GspFirmwareMode not supported by the driver")
+		} else if err != nil {
+			c.queryFailed(err, i, "GspFirmwareMode")
+		} else {
+			c.gspFirmwareEnabled.WithLabelValues(minor, uuid, name).Set(boolToFloat64(gspEnabled))
+			c.gspFirmwareDefaultEnabled.WithLabelValues(minor, uuid, name).Set(boolToFloat64(gspDefaultEnabled))
+		}
+
+		// Only GRID and some Tesla and Quadro cards can switch modes.
+		operatingMode, _, err := dev.GpuOperationMode()
+		if err != nil {
+			c.queryFailed(err, i, "GpuOperationMode")
+		} else {
+			c.operatingMode.WithLabelValues(minor, uuid, name, operatingModeName(operatingMode)).Set(1)
+		}
+
+		migModeCurrent, migModePending, err := dev.MigMode()
+		if err != nil {
+			c.queryFailed(err, i, "MigMode")
+		} else {
+			c.migModeCurrent.WithLabelValues(minor, uuid, name).Set(boolToFloat64(migModeCurrent))
+			c.migModePending.WithLabelValues(minor, uuid, name).Set(boolToFloat64(migModePending))
+			c.migModeEnabled.WithLabelValues(minor, uuid, name, "current").Set(boolToFloat64(migModeCurrent))
+			c.migModeEnabled.WithLabelValues(minor, uuid, name, "pending").Set(boolToFloat64(migModePending))
+
+			if migModeCurrent {
+				c.collectMig(dev, i, minor, uuid, name)
+			}
+		}
+
+		displayActive, err := dev.DisplayActive()
+		if err != nil {
+			c.queryFailed(err, i, "DisplayActive")
+		} else {
+			c.displayActive.WithLabelValues(minor, uuid, name).Set(boolToFloat64(displayActive))
+		}
+
+		displayMode, err := dev.DisplayMode()
+		if err != nil {
+			c.queryFailed(err, i, "DisplayMode")
+		} else {
+			c.displayMode.WithLabelValues(minor, uuid, name).Set(boolToFloat64(displayMode))
+		}
+		// NVML only tells whether any display is attached. Devices without
+		// display outputs can't have one attached, so they report 0 too.
+		if err == nil {
+			c.attachedDisplays.WithLabelValues(minor, uuid, name).Set(boolToFloat64(displayMode))
+		} else if isNVMLError(err, gonvml.ErrorNotSupported) {
+			c.attachedDisplays.WithLabelValues(minor, uuid, name).Set(0)
+		}
+
+		c.collectClockOffsets(dev, i, minor, uuid, name)
+
+		// Not supported by devices without NVENC.
+		encoderSessions, _, encoderLatency, err := dev.EncoderStats()
+		if err != nil {
+			c.queryFailed(err, i, "EncoderStats")
+		} else {
+			c.encoderSessions.WithLabelValues(minor, uuid, name).Set(float64(encoderSessions))
+			c.encoderAverageLatency.WithLabelValues(minor, uuid, name).Set(float64(encoderLatency))
+		}
+
+		c.collectNvLinks(dev, i, minor, uuid, name)
+		c.collectFabric(dev, i, minor, uuid, name)
+
+		// Only Grace Hopper and later systems have C2C links between the GPU
+		// and the CPU.
+		if info.c2cLinks > 0 {
+			c.collectC2C(dev, i, info.c2cLinks, minor, uuid, name)
+		}
+
+		processes, err := dev.ComputeRunningProcesses()
+		if err != nil {
+			c.queryFailed(err, i, "ComputeRunningProcesses")
+		} else {
+			c.processCount.WithLabelValues(minor, uuid, name).Set(float64(len(processes)))
+		}
+
+		if *collectProcesses {
+			c.collectProcesses(dev, i, minor, uuid, name)
+		}
+
+		c.collectLicenses(dev, i, minor, uuid, name)
+
+		if *collectVgpus {
+			c.collectVgpus(dev, i, info, minor, uuid, name)
+		}
+
+		if *collectAccounting {
+			c.collectAccounting(dev, i, minor, uuid, name)
+		}
+
+		if len(fieldIDs) > 0 {
+			c.collectFields(dev, i, minor, uuid, name)
+		}
+
+		if *collectGpm && info.gpmSupported {
+			c.collectGpm(dev, i, minor, uuid, name)
+		}
+	}
+	c.usedMemorySum.WithLabelValues().Set(memoryValue(usedMemorySum))
+	c.totalMemorySum.WithLabelValues().Set(memoryValue(totalMemorySum))
+	c.powerUsageSum.WithLabelValues().Set(powerUsageSum)
+
+	c.freeGpmSamples(seen)
+	c.forgetDevices(seen)
+}
+
+// averageWindow returns the window to average the utilization and the power
+// usage of the device with the given UUID over, which is
+// -collector.utilization.window or, if that is 0, the time since the device was
+// last read. The latter is not known the first time the device is read. The
+// caller must hold the lock.
+func (c *Collector) averageWindow(uuid string) (time.Duration, bool) {
+	now := time.Now()
+	last, ok := c.readTimes[uuid]
+	c.readTimes[uuid] = now
+	if *utilizationWindow > 0 {
+		return *utilizationWindow, true
+	}
+	return now.Sub(last), ok
+}
+
+// observeUtilization observes the utilization samples the driver took since the
+// device was last read in nvidia_gpu_utilization_samples_percent. The samples in the
+// driver's buffer when the device is first read are skipped, they may be from
+// long before. The caller must hold the lock.
+func (c *Collector) observeUtilization(dev nvmlDevice, minor, uuid, name string) error {
+	last, seen := c.utilizationSampleTimes[uuid]
+	samples, err := dev.Samples(gonvml.SamplingTypeGPUUtilization, last)
+	// No samples yet, all samples from now on are new.
+	if isNVMLError(err, gonvml.ErrorNotFound) {
+		c.utilizationSampleTimes[uuid] = last
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	observer := c.utilizationHistogram.WithLabelValues(minor, uuid, name)
+	for _, sample := range samples {
+		if sample.TimeStamp <= last {
+			continue
+		}
+		if sample.TimeStamp > c.utilizationSampleTimes[uuid] {
+			c.utilizationSampleTimes[uuid] = sample.TimeStamp
+		}
+		if seen {
+			observer.Observe(float64(sample.Value))
+		}
+	}
+	return nil
+}
+
+// sampleDutyCycle observes -collect.duty-cycle-samples utilization samples of
+// the device in the nvidia_gpu_duty_cycle histogram, starting with first, the
+// utilization just read. The samples are -collect.duty-cycle-sample-interval
+// apart. The caller must hold the lock.
+func (c *Collector) sampleDutyCycle(dev nvmlDevice, i int, minor, uuid, name string, first uint) {
+	observer := c.dutyCycleHistogram.WithLabelValues(minor, uuid, name)
+	observer.Observe(float64(first))
+	for n := 1; n < *dutyCycleSamples; n++ {
+		time.Sleep(*dutyCycleSampleInterval)
+		utilization, _, err := dev.UtilizationRates()
+		if err != nil {
+			c.queryFailed(err, i, "UtilizationRates")
+			return
+		}
+		observer.Observe(float64(utilization))
+	}
+}
+
+// utilizationSamplePeriod returns the average time between the utilization
+// samples in the driver's buffer, i.e. the period the driver samples the
+// utilization at. ok is false if there are fewer than two samples.
+func utilizationSamplePeriod(dev nvmlDevice) (period time.Duration, ok bool, err error) {
+	samples, err := dev.Samples(gonvml.SamplingTypeGPUUtilization, 0)
+	if isNVMLError(err, gonvml.ErrorNotFound) {
+		return 0, false, nil
+	}
+	if err != nil || len(samples) < 2 {
+		return 0, false, err
+	}
+
+	first, last := samples[0].TimeStamp, samples[0].TimeStamp
+	for _, sample := range samples[1:] {
+		if sample.TimeStamp < first {
+			first = sample.TimeStamp
+		}
+		if sample.TimeStamp > last {
+			last = sample.TimeStamp
+		}
+	}
+	// The timestamps are in microseconds.
+	period = time.Duration(last-first) * time.Microsecond / time.Duration(len(samples)-1)
+	return period, true, nil
+}
+
+// maxPowerUsage returns the maximum of the power samples the driver took since
+// the device was last read. ok is false if there are none. The caller must hold
+// the lock.
+func (c *Collector) maxPowerUsage(dev nvmlDevice, uuid string) (max uint64, ok bool, err error) {
+	samples, err := dev.Samples(gonvml.SamplingTypeTotalPower, c.powerSampleTimes[uuid])
+	if isNVMLError(err, gonvml.ErrorNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
 
-	labels = []string{"minor_number", "uuid", "name"}
-)
+	for _, sample := range samples {
+		if sample.TimeStamp > c.powerSampleTimes[uuid] {
+			c.powerSampleTimes[uuid] = sample.TimeStamp
+		}
+		if !ok || sample.Value > max {
+			max = sample.Value
+			ok = true
+		}
+	}
+	return max, ok, nil
+}
 
-type Collector struct {
-	sync.Mutex
-	numDevices  prometheus.Gauge
-	usedMemory  *prometheus.GaugeVec
-	totalMemory *prometheus.GaugeVec
-	dutyCycle   *prometheus.GaugeVec
-	powerUsage  *prometheus.GaugeVec
-	temperature *prometheus.GaugeVec
-	fanSpeed    *prometheus.GaugeVec
+// applicationsClockIsDefault reports whether the graphics and memory
+// applications clocks of dev are set to their defaults.
+func applicationsClockIsDefault(dev nvmlDevice) (bool, error) {
+	for _, clockType := range []gonvml.ClockType{gonvml.ClockGraphics, gonvml.ClockMem} {
+		current, err := dev.ApplicationsClock(clockType)
+		if err != nil {
+			return false, err
+		}
+		defaultClock, err := dev.DefaultApplicationsClock(clockType)
+		if err != nil {
+			return false, err
+		}
+		if current != defaultClock {
+			return false, nil
+		}
+	}
+	return true, nil
 }
 
-func NewCollector() *Collector {
-	return &Collector{
-		numDevices: prometheus.NewGauge(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "num_devices",
-				Help:      "Number of GPU devices",
-			},
-		),
-		usedMemory: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "memory_used_bytes",
-				Help:      "Memory used by the GPU device in bytes",
-			},
-			labels,
-		),
-		totalMemory: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "memory_total_bytes",
-				Help:      "Total memory of the GPU device in bytes",
-			},
-			labels,
-		),
-		dutyCycle: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "duty_cycle",
-				Help:      "Percent of time over the past sample period during which one or more kernels were executing on the GPU device",
-			},
-			labels,
-		),
-		powerUsage: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "power_usage_milliwatts",
-				Help:      "Power usage of the GPU device in milliwatts",
-			},
-			labels,
-		),
-		temperature: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "temperature_celsius",
-				Help:      "Temperature of the GPU device in celsius",
-			},
-			labels,
-		),
-		fanSpeed: prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Namespace: namespace,
-				Name:      "fanspeed_percent",
-				Help:      "Fanspeed of the GPU device as a percent of its maximum",
-			},
-			labels,
-		),
+// setTemperature sets nvidia_gpu_temperature_celsius for the given sensor. With
+// -compat.unlabeled-temperature, only the GPU sensor is exported and without
+// the sensor label.
+func (c *Collector) setTemperature(minor, uuid, name, sensor string, temperature uint) {
+	if *unlabeledTemperature {
+		if sensor == "gpu" {
+			c.temperature.WithLabelValues(minor, uuid, name).Set(float64(temperature))
+		}
+		return
 	}
+	c.temperature.WithLabelValues(minor, uuid, name, sensor).Set(float64(temperature))
 }
 
-func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.numDevices.Desc()
-	c.usedMemory.Describe(ch)
-	c.totalMemory.Describe(ch)
-	c.dutyCycle.Describe(ch)
-	c.powerUsage.Describe(ch)
-	c.temperature.Describe(ch)
-	c.fanSpeed.Describe(ch)
+// setDeviceUp sets nvidia_gpu_device_up for the device with index i if
+// -collect.device-up is set. The index stands in for the minor number if that
+// is unknown.
+func (c *Collector) setDeviceUp(i int, minor string, up bool) {
+	if !*deviceUp {
+		return
+	}
+	if minor == "" {
+		minor = strconv.Itoa(i)
+	}
+	c.deviceUp.WithLabelValues(minor).Set(boolToFloat64(up))
 }
 
-func (c *Collector) Collect(ch chan<- prometheus.Metric) {
-	// Only one Collect call in progress at a time.
-	c.Lock()
-	defer c.Unlock()
+// forgetDevices drops everything kept about the devices that were seen by the
+// previous update but not in seen, e.g. because they were detached. Most
+// gauges are reset on every update, but the other vectors are not and need
+// their series of such devices deleted explicitly. The caller must hold the
+// lock.
+func (c *Collector) forgetDevices(seen map[string]bool) {
+	for uuid := range c.devices {
+		if seen[uuid] {
+			continue
+		}
 
-	c.usedMemory.Reset()
-	c.totalMemory.Reset()
-	c.dutyCycle.Reset()
-	c.powerUsage.Reset()
-	c.temperature.Reset()
-	c.fanSpeed.Reset()
+		log.Info().
+			Str("uuid", uuid).
+			Msg("Device is gone, deleting its series")
+		for _, v := range c.kept {
+			deleteDeviceSeries(v, uuid)
+		}
 
-	numDevices, err := gonvml.DeviceCount()
-	if err != nil {
-		log.Error().Err(err).Msg("Cannot get DeviceCount")
-		return
-	} else {
-		c.numDevices.Set(float64(numDevices))
-		ch <- c.numDevices
+		delete(c.static, uuid)
+		delete(c.readTimes, uuid)
+		delete(c.powerSampleTimes, uuid)
+		delete(c.utilizationSampleTimes, uuid)
+		for key := range c.counterValues {
+			if strings.HasPrefix(key, uuid+"/") {
+				delete(c.counterValues, key)
+			}
+		}
 	}
+	c.devices = seen
+}
 
-	for i := 0; i < int(numDevices); i++ {
-		// Device information
-		dev, err := gonvml.DeviceHandleByIndex(uint(i))
-		if err != nil {
-			log.Warn().
-				Err(err).
-				Int("device_index", i).
-				Msg("Cannot get DeviceHandleByIndex")
+// deleteDeviceSeries deletes the series of vec whose uuid label is uuid.
+func deleteDeviceSeries(vec vec, uuid string) {
+	// The series can't be deleted while vec is collecting them.
+	ch := make(chan prometheus.Metric)
+	go func() {
+		vec.Collect(ch)
+		close(ch)
+	}()
+
+	var stale []prometheus.Labels
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
 			continue
 		}
+		labels := make(prometheus.Labels)
+		for _, l := range pb.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		if labels["uuid"] == uuid {
+			stale = append(stale, labels)
+		}
+	}
 
-		minorNumber, err := dev.MinorNumber()
-		if err != nil {
-			log.Warn().
-				Err(err).
-				Int("device_index", i).
-				Msg("Cannot get device MinorNumber")
-			continue
+	for _, labels := range stale {
+		vec.Delete(labels)
+	}
+}
+
+// formatCudaVersion formats a CUDA version encoded as 1000*major + 10*minor,
+// the encoding of nvidia_gpu_cuda_driver_version, as major.minor.
+func formatCudaVersion(version int) string {
+	return strconv.Itoa(version/1000) + "." + strconv.Itoa(version%1000/10)
+}
+
+// deviceLabels returns the values of the standard labels for the device with
+// index i. With -collect.name-cache they are only queried the first time the
+// device is seen. The caller must hold the lock.
+func (c *Collector) deviceLabels(dev nvmlDevice, i int) (minor, uuid, name string, ok bool) {
+	if labels, ok := c.labelCache[i]; ok {
+		return labels.minor, labels.uuid, labels.name, true
+	}
+
+	minor, uuid, name, query, err := deviceLabelValues(dev)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Int("device_index", i).
+			Msg("Cannot get device " + query)
+		c.countError(err)
+		return "", "", "", false
+	}
+	if minor == "" {
+		log.Debug().
+			Int("device_index", i).
+			Msg("Device has no minor number")
+	}
+
+	if *nameCache {
+		c.labelCache[i] = cachedLabels{minor, uuid, name}
+	}
+	return minor, uuid, name, true
+}
+
+// deviceLabelValues queries the values of the standard labels for dev. Devices
+// passed to vGPU guests don't have a minor number, the label is left empty for
+// them. If err is set, query is the query that failed.
+func deviceLabelValues(dev nvmlDevice) (minor, uuid, name, query string, err error) {
+	minorNumber, err := dev.MinorNumber()
+	if err == nil {
+		minor = strconv.Itoa(int(minorNumber))
+	} else if !isNVMLError(err, gonvml.ErrorNotSupported) {
+		return "", "", "", "MinorNumber", err
+	}
+
+	uuid, err = dev.UUID()
+	if err != nil {
+		return "", "", "", "UUID", err
+	}
+
+	name, err = deviceName(dev)
+	if err != nil {
+		return "", "", "", "Name", err
+	}
+	return minor, uuid, name, "", nil
+}
+
+// deviceName returns the name of dev, sanitized with -collect.sanitize-names.
+func deviceName(dev nvmlDevice) (string, error) {
+	name, err := dev.Name()
+	if err != nil || !*sanitizeNames {
+		return name, err
+	}
+	return sanitizeName(name), nil
+}
+
+// sanitizeName strips non-printable characters from name and collapses runs
+// of whitespace into a single space.
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPrint(r) {
+			return r
 		}
-		minor := strconv.Itoa(int(minorNumber))
+		return -1
+	}, name)
+	return strings.Join(strings.Fields(name), " ")
+}
 
-		uuid, err := dev.UUID()
-		if err != nil {
+// queryFailed handles the failure of a query of the device with index i.
+// Failures due to insufficient permissions repeat on every scrape until the
+// exporter is run with other permissions, so they are flagged by the
+// permission_denied metric and only logged the first time. The caller must
+// hold the lock.
+func (c *Collector) queryFailed(err error, i int, query string) {
+	c.countError(err)
+
+	if isNVMLError(err, gonvml.ErrorNoPermission) {
+		if !c.deniedQueries[query] {
+			c.deniedQueries[query] = true
+			c.permissionDenied.WithLabelValues(query).Set(1)
 			log.Warn().
 				Err(err).
 				Int("device_index", i).
-				Msg("Cannot get device UUID")
-			continue
+				Str("query", query).
+				Msg("Insufficient permissions for query")
 		}
+		return
+	}
+
+	log.Debug().
+		Err(err).
+		Int("device_index", i).
+		Msg("Cannot get " + query)
+}
+
+// countError counts a failed query by its NVML return code.
+func (c *Collector) countError(err error) {
+	c.nvmlErrors.WithLabelValues(nvmlErrorCode(err)).Inc()
+}
+
+// retry calls query until it doesn't fail with an unknown error, which some
+// queries intermittently do under load, or it has been retried
+// -collect.query-retries times. It returns the error of the last call.
+func (c *Collector) retry(name string, query func() error) error {
+	delay := queryRetryDelay
+	err := query()
+	for retries := 0; retries < *queryRetries && isNVMLError(err, gonvml.ErrorUnknown); retries++ {
+		time.Sleep(delay)
+		delay *= 2
+		c.queryRetries.WithLabelValues(name).Inc()
+		err = query()
+	}
+	return err
+}
+
+// addDelta adds the increase of a cumulative device counter since it was last
+// read to counter. key identifies the device counter. Nothing is added the
+// first time a device counter is read. When it went backwards, e.g. because
+// the GPU was reset, its whole value is taken as the increase. The caller must
+// hold the lock.
+func (c *Collector) addDelta(counter prometheus.Counter, key string, value uint64) {
+	last, ok := c.counterValues[key]
+	c.counterValues[key] = value
+	if !ok {
+		return
+	}
+	if value < last {
+		last = 0
+	}
+	counter.Add(float64(value - last))
+}
+
+// operatingModeNames maps nvmlGpuOperationMode_t values to label values.
+var operatingModeNames = map[uint]string{
+	0: "all_on",
+	1: "compute",
+	2: "low_dp",
+}
+
+func operatingModeName(mode uint) string {
+	if name, ok := operatingModeNames[mode]; ok {
+		return name
+	}
+	return "unknown"
+}
 
-		name, err := dev.Name()
+func boolToFloat64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// nvlinkErrorTypes are the NVLink error counters by the value of the type label.
+var nvlinkErrorTypes = []struct {
+	name    string
+	counter gonvml.NvLinkErrorCounter
+}{
+	{"crc_flit", gonvml.NvLinkErrorDLCRCFlit},
+	{"crc_data", gonvml.NvLinkErrorDLCRCData},
+	{"replay", gonvml.NvLinkErrorDLReplay},
+	{"recovery", gonvml.NvLinkErrorDLRecovery},
+}
+
+// collectNvLinks reads the state, the traffic and the errors of the NVLink
+// links of the device. Devices without NVLink don't produce any series.
+func (c *Collector) collectNvLinks(dev nvmlDevice, i int, minor, uuid, name string) {
+	for link := uint(0); link < gonvml.NvLinkMaxLinks; link++ {
+		active, err := dev.NvLinkState(link)
+		// NVML reports links beyond the last one of the device as invalid
+		// arguments, and all of them as not supported without NVLink.
+		if isNVMLError(err, gonvml.ErrorInvalidArgument) || isNVMLError(err, gonvml.ErrorNotSupported) {
+			break
+		}
 		if err != nil {
-			log.Warn().
+			log.Debug().
 				Err(err).
 				Int("device_index", i).
-				Msg("Cannot get device Name")
+				Uint("link", link).
+				Msg("Cannot get NvLinkState")
+			c.countError(err)
+			continue
+		}
+		l := strconv.FormatUint(uint64(link), 10)
+		c.nvlinkActive.WithLabelValues(minor, uuid, name, l).Set(boolToFloat64(active))
+		if !active {
 			continue
 		}
 
-		// Metrics
-		totalMemory, usedMemory, err := dev.MemoryInfo()
+		// The throughput counters are in KiB.
+		tx, rx, err := dev.NvLinkThroughput(link)
 		if err != nil {
 			log.Debug().
 				Err(err).
 				Int("device_index", i).
-				Msg("Cannot get MemoryInfo")
+				Uint("link", link).
+				Msg("Cannot get NvLinkThroughput")
+			c.countError(err)
 		} else {
-			c.usedMemory.WithLabelValues(minor, uuid, name).Set(float64(usedMemory))
-			c.totalMemory.WithLabelValues(minor, uuid, name).Set(float64(totalMemory))
+			c.addDelta(c.nvlinkTx.WithLabelValues(minor, uuid, name, l), uuid+"/nvlink_tx/"+l, tx*1024)
+			c.addDelta(c.nvlinkRx.WithLabelValues(minor, uuid, name, l), uuid+"/nvlink_rx/"+l, rx*1024)
 		}
 
-		dutyCycle, _, err := dev.UtilizationRates()
-		if err != nil {
-			log.Debug().
-				Err(err).
-				Int("device_index", i).
-				Msg("Cannot get UtilizationRates")
-		} else {
-			c.dutyCycle.WithLabelValues(minor, uuid, name).Set(float64(dutyCycle))
+		for _, t := range nvlinkErrorTypes {
+			count, err := dev.NvLinkErrorCounter(link, t.counter)
+			if err != nil {
+				log.Debug().
+					Err(err).
+					Int("device_index", i).
+					Uint("link", link).
+					Str("type", t.name).
+					Msg("Cannot get NvLinkErrorCounter")
+				c.countError(err)
+				continue
+			}
+			c.addDelta(c.nvlinkErrors.WithLabelValues(minor, uuid, name, l, t.name), uuid+"/nvlink_errors/"+l+"/"+t.name, count)
 		}
+	}
+}
+
+// collectC2C reads the state and bandwidth of the C2C links of the device.
+func (c *Collector) collectC2C(dev nvmlDevice, i int, links uint, minor, uuid, name string) {
+	c.c2cLinks.WithLabelValues(minor, uuid, name).Set(float64(links))
+
+	active, err := dev.C2CLinkStatus()
+	if err != nil {
+		c.queryFailed(err, i, "C2CLinkStatus")
+	} else {
+		c.c2cActive.WithLabelValues(minor, uuid, name).Set(boolToFloat64(active))
+	}
 
-		powerUsage, err := dev.PowerUsage()
+	for link := uint(0); link < links; link++ {
+		// In MB/s.
+		bandwidth, err := dev.C2CLinkMaxBandwidth(link)
 		if err != nil {
 			log.Debug().
 				Err(err).
 				Int("device_index", i).
-				Msg("Cannot get PowerUsage")
-		} else {
-			c.powerUsage.WithLabelValues(minor, uuid, name).Set(float64(powerUsage))
+				Uint("link", link).
+				Msg("Cannot get C2CLinkMaxBandwidth")
+			c.countError(err)
+			continue
 		}
+		l := strconv.FormatUint(uint64(link), 10)
+		c.c2cMaxBandwidth.WithLabelValues(minor, uuid, name, l).Set(float64(bandwidth) * 1e6)
+	}
+}
 
-		temperature, err := dev.Temperature()
-		if err != nil {
-			log.Debug().
-				Err(err).
-				Int("device_index", i).
-				Msg("Cannot get Temperature")
-		} else {
-			c.temperature.WithLabelValues(minor, uuid, name).Set(float64(temperature))
+// NVML_GPU_FABRIC_STATE_* values.
+const (
+	fabricStateNotSupported = 0
+	fabricStateCompleted    = 3
+)
+
+// collectFabric reads the state of the registration of the device with the
+// NVLink fabric on NVSwitch systems. Nothing is exported on other systems.
+func (c *Collector) collectFabric(dev nvmlDevice, i int, minor, uuid, name string) {
+	fabric, err := dev.GpuFabricInfo()
+	if err != nil {
+		c.queryFailed(err, i, "GpuFabricInfo")
+		return
+	}
+	if fabric.State == fabricStateNotSupported {
+		log.Debug().Int("device_index", i).Msg("Device is not attached to an NVLink fabric")
+		return
+	}
+
+	c.fabricState.WithLabelValues(minor, uuid, name).Set(float64(fabric.State))
+	// The status and the fabric are only known once registration completed.
+	if fabric.State != fabricStateCompleted {
+		return
+	}
+	status := "success"
+	if fabric.Status != nil {
+		status = nvmlErrorCode(fabric.Status)
+	}
+	c.fabricStatus.WithLabelValues(minor, uuid, name, status).Set(1)
+	if fabric.Status == nil {
+		cliqueID := strconv.FormatUint(uint64(fabric.CliqueID), 10)
+		c.fabricInfo.WithLabelValues(minor, uuid, name, fabric.ClusterUUID, cliqueID).Set(1)
+	}
+}
+
+// collectProcesses reads the memory used by the processes that currently have
+// a compute or graphics context on the device.
+func (c *Collector) collectProcesses(dev nvmlDevice, i int, minor, uuid, name string) {
+	seen := make(map[uint]bool)
+
+	computeProcesses, err := dev.ComputeRunningProcesses()
+	if err != nil {
+		c.queryFailed(err, i, "ComputeRunningProcesses")
+	}
+	for _, p := range computeProcesses {
+		seen[p.PID] = true
+		c.processMemory.WithLabelValues(processLabelValues(minor, uuid, name, p.PID, "compute")...).Set(memoryValue(float64(p.UsedGPUMemory)))
+	}
+
+	graphicsProcesses, err := dev.GraphicsRunningProcesses()
+	if err != nil {
+		c.queryFailed(err, i, "GraphicsRunningProcesses")
+	}
+	for _, p := range graphicsProcesses {
+		// A process with both kinds of contexts is listed twice with the same
+		// memory usage; report it once, as compute.
+		if seen[p.PID] {
+			continue
+		}
+		c.processMemory.WithLabelValues(processLabelValues(minor, uuid, name, p.PID, "graphics")...).Set(memoryValue(float64(p.UsedGPUMemory)))
+	}
+}
+
+// processLabelValues returns the label values of
+// nvidia_gpu_process_memory_used_bytes, including the command with
+// -collect.process-cmdline.
+func processLabelValues(minor, uuid, name string, pid uint, processType string) []string {
+	values := []string{minor, uuid, name, strconv.FormatUint(uint64(pid), 10), processType}
+	if *processCmdline {
+		values = append(values, processCommand(pid))
+	}
+	return values
+}
+
+// processCommand returns the command name of the process with the given PID.
+// The process may have exited since the driver listed it, or run in another
+// PID namespace, so the command is empty if it cannot be read.
+func processCommand(pid uint) string {
+	comm, err := ioutil.ReadFile("/proc/" + strconv.FormatUint(uint64(pid), 10) + "/comm")
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Uint("pid", pid).
+			Msg("Cannot read process command")
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
+
+// collectAccounting reads the statistics the driver keeps for every process
+// that ran on the device while accounting mode was enabled, including ones
+// that have already terminated. The driver keeps them in a circular buffer, so
+// an entry returned by AccountingPids may be overwritten before we get to read
+// it; such entries are skipped.
+func (c *Collector) collectAccounting(dev nvmlDevice, i int, minor, uuid, name string) {
+	enabled, err := dev.AccountingMode()
+	if err != nil {
+		c.queryFailed(err, i, "AccountingMode")
+		return
+	}
+	if !enabled {
+		c.accountingEnabled.WithLabelValues(minor, uuid, name).Set(0)
+		return
+	}
+	c.accountingEnabled.WithLabelValues(minor, uuid, name).Set(1)
+
+	bufferSize, err := dev.AccountingBufferSize()
+	if err != nil {
+		c.queryFailed(err, i, "AccountingBufferSize")
+	} else {
+		c.accountingBufferSize.WithLabelValues(minor, uuid, name).Set(float64(bufferSize))
+	}
+
+	pids, err := dev.AccountingPids()
+	if err != nil {
+		c.queryFailed(err, i, "AccountingPids")
+		return
+	}
+
+	// A pid shows up more than once if it was reused while still in the
+	// buffer; AccountingStats always returns the most recent entry.
+	seen := make(map[uint]bool, len(pids))
+	for _, pid := range pids {
+		if seen[pid] {
+			continue
 		}
+		seen[pid] = true
 
-		fanSpeed, err := dev.FanSpeed()
+		stats, err := dev.AccountingStats(pid)
 		if err != nil {
 			log.Debug().
 				Err(err).
 				Int("device_index", i).
-				Msg("Cannot get FanSpeed")
-		} else {
-			c.fanSpeed.WithLabelValues(minor, uuid, name).Set(float64(fanSpeed))
+				Uint("pid", pid).
+				Msg("Cannot get AccountingStats")
+			c.countError(err)
+			continue
 		}
+		p := strconv.FormatUint(uint64(pid), 10)
+		c.accountingMaxMemory.WithLabelValues(minor, uuid, name, p).Set(memoryValue(float64(stats.MaxMemoryUsage)))
+		c.accountingGPUUtilization.WithLabelValues(minor, uuid, name, p).Set(float64(stats.GPUUtilization))
+		c.accountingMemoryUtilization.WithLabelValues(minor, uuid, name, p).Set(float64(stats.MemoryUtilization))
+		c.accountingTime.WithLabelValues(minor, uuid, name, p).Set(float64(stats.Time))
 	}
-	c.usedMemory.Collect(ch)
-	c.totalMemory.Collect(ch)
-	c.dutyCycle.Collect(ch)
-	c.powerUsage.Collect(ch)
-	c.temperature.Collect(ch)
-	c.fanSpeed.Collect(ch)
 }
 
 func main() {
@@ -233,6 +1650,38 @@ func main() {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
 
+	var err error
+	fieldIDs, err = parseFieldIDs(*collectFields)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid -collector.fields")
+	}
+
+	if *otlpEndpoint != "" && *collectInterval <= 0 {
+		log.Fatal().Msg("-otlp.endpoint requires -collect.interval")
+	}
+
+	if *hostNamespace {
+		if err := enterHostNamespace(); err != nil {
+			log.Fatal().
+				Err(err).
+				Msg("Cannot enter the host namespace")
+		}
+	}
+	memoryDivisor, err = parseMemoryUnit(*memoryUnit)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid -collect.memory-unit")
+	}
+	utilizationBuckets, err = parseBuckets(*utilizationBucketsFlag)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Msg("Invalid -collect.utilization-buckets")
+	}
+
 	if err := gonvml.Initialize(); err != nil {
 		log.Fatal().
 			Err(err).
@@ -246,14 +1695,39 @@ func main() {
 	} else {
 		log.Info().Msgf("SystemDriverVersion(): %v", driverVersion)
 	}
+	if cudaDriverVersion, err := gonvml.SystemCudaDriverVersion(); err != nil {
+		log.Debug().
+			Err(err).
+			Msg("Cannot get SystemCudaDriverVersion()")
+	} else {
+		log.Info().Msgf("SystemCudaDriverVersion(): %v", formatCudaVersion(cudaDriverVersion))
+	}
 
-	prometheus.MustRegister(NewCollector())
+	collector := NewCollector()
+	prometheus.MustRegister(collector)
 
-	// Serve on all paths under addr
-	log.Info().Msgf("Listening on %s", *addr)
-	log.Error().
-		Err(http.ListenAndServe(*addr, promhttp.Handler())).
-		Msg("Shutting down")
+	exitCode := 0
+	if *selfTestRun {
+		if err := selfTest(os.Stdout); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Cannot run self-test")
+			exitCode = 1
+		}
+	} else if *dryRun {
+		if err := writeMetrics(os.Stdout, collector); err != nil {
+			log.Error().
+				Err(err).
+				Msg("Cannot write metrics")
+			exitCode = 1
+		}
+	} else {
+		serve(collector)
+	}
+
+	collector.Lock()
+	collector.freeGpmSamples(nil)
+	collector.Unlock()
 
 	if err := gonvml.Shutdown(); err != nil {
 		log.Error().
@@ -262,4 +1736,97 @@ func main() {
 	} else {
 		log.Info().Msg("Shutting down NVML")
 	}
+	os.Exit(exitCode)
+}
+
+// serve serves the metrics until the server fails or the exporter is asked to
+// stop.
+func serve(collector *Collector) {
+	if *collectInterval > 0 {
+		go collector.poll(*collectInterval)
+	} else if *collectOnDemand {
+		collector.refresh()
+	}
+	if *otlpEndpoint != "" {
+		go runOTLP(*otlpEndpoint, *collectInterval, prometheus.DefaultGatherer)
+	}
+
+	stopEvents := make(chan struct{})
+	eventsDone := make(chan struct{})
+	if *enableEvents {
+		go collector.watchEvents(stopEvents, eventsDone)
+	} else {
+		close(eventsDone)
+	}
+
+	log.Info().Msgf("Listening on %s", *addr)
+	server := &http.Server{
+		Addr:         *addr,
+		Handler:      newMux(collector),
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
+		log.Error().
+			Err(err).
+			Msg("Shutting down")
+	case sig := <-signals:
+		log.Info().
+			Str("signal", sig.String()).
+			Msg("Shutting down")
+	}
+
+	// The event set has to be freed before NVML is shut down.
+	close(stopEvents)
+	<-eventsDone
+}
+
+// newMux returns the handler of the HTTP endpoints of the exporter.
+func newMux(collector *Collector) *http.ServeMux {
+	mux := http.NewServeMux()
+	// Serve on all paths under addr
+	mux.Handle("/", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+			DisableCompression: !*compression,
+		}),
+	))
+	mux.HandleFunc("/collect", collector.handleCollect)
+	if *enablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	return mux
+}
+
+// writeMetrics collects the metrics once and writes them to w in the text
+// exposition format.
+func writeMetrics(w io.Writer, collector *Collector) error {
+	if !readOnScrape() {
+		collector.refresh()
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return err
+	}
+	encoder := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return err
+		}
+	}
+	return nil
 }
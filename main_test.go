@@ -0,0 +1,663 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/xofym/gonvml"
+)
+
+// collectSeries returns the series collected from c.
+func collectSeries(c prometheus.Collector) []*dto.Metric {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var series []*dto.Metric
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			continue
+		}
+		series = append(series, &pb)
+	}
+	return series
+}
+
+// seriesLabels returns the labels of a series by name.
+func seriesLabels(m *dto.Metric) map[string]string {
+	labels := make(map[string]string)
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+// seriesUUIDs returns the values of the uuid label of the series collected
+// from c.
+func seriesUUIDs(c prometheus.Collector) map[string]bool {
+	uuids := make(map[string]bool)
+	for _, m := range collectSeries(c) {
+		if uuid, ok := seriesLabels(m)["uuid"]; ok {
+			uuids[uuid] = true
+		}
+	}
+	return uuids
+}
+
+// sumSeries returns the sum of the values of the gauges collected from c.
+func sumSeries(c prometheus.Collector) float64 {
+	var sum float64
+	for _, m := range collectSeries(c) {
+		sum += m.GetGauge().GetValue()
+	}
+	return sum
+}
+
+func TestCudaVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		encoded int
+	}{
+		{"9.2", 9020},
+		{"10.1", 10010},
+		{"11.8", 11080},
+		{"12.0", 12000},
+		{"12.4", 12040},
+		{"12.10", 12100},
+	}
+
+	for _, tt := range tests {
+		var major, minor int
+		if _, err := fmt.Sscanf(tt.version, "%d.%d", &major, &minor); err != nil {
+			t.Fatalf("cannot parse %q: %v", tt.version, err)
+		}
+		if got := 1000*major + 10*minor; got != tt.encoded {
+			t.Errorf("encoding of %s = %d, want %d", tt.version, got, tt.encoded)
+		}
+		if got := formatCudaVersion(tt.encoded); got != tt.version {
+			t.Errorf("formatCudaVersion(%d) = %q, want %q", tt.encoded, got, tt.version)
+		}
+	}
+}
+
+func TestCudaDriverVersion(t *testing.T) {
+	c := NewCollector()
+	c.nvml = &fakeNVML{cudaDriverVersion: 12040}
+	c.update()
+
+	if got := testutil.ToFloat64(c.cudaDriverVersion); got != 12040 {
+		t.Errorf("cuda_driver_version = %v, want 12040", got)
+	}
+}
+
+func TestDriverInfo(t *testing.T) {
+	tests := []struct {
+		name          string
+		driverVersion string
+		nvmlVersion   string
+		want          map[string]string
+	}{
+		{
+			name:          "both versions",
+			driverVersion: "550.54.15",
+			nvmlVersion:   "12.550.54.15",
+			want:          map[string]string{"driver_version": "550.54.15", "nvml_version": "12.550.54.15"},
+		},
+		{
+			name:          "driver version only",
+			driverVersion: "550.54.15",
+			want:          map[string]string{"driver_version": "550.54.15", "nvml_version": ""},
+		},
+		{
+			name: "no versions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCollector()
+			c.nvml = &fakeNVML{driverVersion: tt.driverVersion, nvmlVersion: tt.nvmlVersion}
+			c.update()
+
+			series := collectSeries(c.driverInfo)
+			if tt.want == nil {
+				if len(series) != 0 {
+					t.Errorf("got driver_info %v, want none", seriesLabels(series[0]))
+				}
+				return
+			}
+			if len(series) != 1 {
+				t.Fatalf("got %d driver_info series, want 1", len(series))
+			}
+			if got := seriesLabels(series[0]); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("driver_info labels = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMuxCompression(t *testing.T) {
+	mux := newMux(NewCollector())
+
+	for _, tt := range []struct {
+		name           string
+		acceptEncoding string
+		gzipped        bool
+	}{
+		{"gzip client", "gzip", true},
+		{"plain client", "", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			body := io.Reader(rec.Body)
+			if encoding := rec.Header().Get("Content-Encoding"); (encoding == "gzip") != tt.gzipped {
+				t.Fatalf("Content-Encoding = %q, want gzip %v", encoding, tt.gzipped)
+			}
+			if tt.gzipped {
+				gz, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = gz
+			}
+			text, err := ioutil.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(text), "go_goroutines") {
+				t.Errorf("body doesn't contain the metrics:\n%s", text)
+			}
+		})
+	}
+}
+
+func TestCollectRevalidatesInforom(t *testing.T) {
+	dev := &fakeDevice{uuid: "GPU-0", name: "Tesla T4"}
+	c := NewCollector()
+	c.nvml = &fakeNVML{devices: []*fakeDevice{dev}}
+	mux := newMux(c)
+
+	c.update()
+	c.update()
+	if dev.inforomValidations != 1 {
+		t.Fatalf("InfoROM validated %d times before POST /collect, want 1", dev.inforomValidations)
+	}
+
+	// Every scrape reads the metrics with the default flags, but /collect
+	// still validates the InfoROM again.
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/collect", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if dev.inforomValidations != 2 {
+		t.Errorf("InfoROM validated %d times after POST /collect, want 2", dev.inforomValidations)
+	}
+	if got := testutil.ToFloat64(c.inforomConfigValid.WithLabelValues("0", "GPU-0", "Tesla T4")); got != 1 {
+		t.Errorf("inforom_config_valid = %v, want 1", got)
+	}
+}
+
+func TestForgetDevices(t *testing.T) {
+	defer func(v bool) { *collectUtilizationHistogram = v }(*collectUtilizationHistogram)
+	*collectUtilizationHistogram = true
+
+	lib := &fakeNVML{}
+	for i := 0; i < 4; i++ {
+		lib.devices = append(lib.devices, &fakeDevice{
+			minor:              uint(i),
+			uuid:               fmt.Sprintf("GPU-%d", i),
+			name:               "Tesla T4",
+			violationTime:      1e9,
+			utilizationSamples: []gonvml.Sample{{TimeStamp: 1, Value: 50}},
+		})
+	}
+	c := NewCollector()
+	c.nvml = lib
+
+	c.update()
+	for _, d := range lib.devices {
+		d.violationTime += 1e9
+		d.utilizationSamples = append(d.utilizationSamples, gonvml.Sample{TimeStamp: 2, Value: 60})
+	}
+	c.update()
+
+	vecs := map[string]prometheus.Collector{
+		"clock_throttle_thermal": c.clockThrottleThermal,
+		"utilization_histogram":  c.utilizationHistogram,
+	}
+	for name, vec := range vecs {
+		if got := len(seriesUUIDs(vec)); got != 4 {
+			t.Fatalf("%s has series of %d devices before devices are gone, want 4", name, got)
+		}
+	}
+
+	lib.devices = lib.devices[:2]
+	c.update()
+
+	want := map[string]bool{"GPU-0": true, "GPU-1": true}
+	for name, vec := range vecs {
+		if got := seriesUUIDs(vec); len(got) != len(want) || !got["GPU-0"] || !got["GPU-1"] {
+			t.Errorf("%s has series of devices %v, want %v", name, got, want)
+		}
+	}
+	for _, uuid := range []string{"GPU-2", "GPU-3"} {
+		if _, ok := c.static[uuid]; ok {
+			t.Errorf("static information of %s kept", uuid)
+		}
+		for key := range c.counterValues {
+			if strings.HasPrefix(key, uuid+"/") {
+				t.Errorf("counter value %s kept", key)
+			}
+		}
+	}
+	for uuid := range want {
+		if _, ok := c.static[uuid]; !ok {
+			t.Errorf("static information of %s dropped", uuid)
+		}
+		if _, ok := c.counterValues[uuid+"/clock_throttle_thermal"]; !ok {
+			t.Errorf("counter value of %s dropped", uuid)
+		}
+	}
+}
+
+func TestAverageWindow(t *testing.T) {
+	c := NewCollector()
+
+	if _, ok := c.averageWindow("GPU-0"); ok {
+		t.Error("window known on first read")
+	}
+
+	c.readTimes["GPU-0"] = time.Now().Add(-10 * time.Second)
+	window, ok := c.averageWindow("GPU-0")
+	if !ok {
+		t.Fatal("window unknown on second read")
+	}
+	if window < 10*time.Second || window > 11*time.Second {
+		t.Errorf("window = %v, want time since last read, 10s", window)
+	}
+}
+
+func TestAverageWindowFixed(t *testing.T) {
+	defer func(d time.Duration) { *utilizationWindow = d }(*utilizationWindow)
+	*utilizationWindow = 30 * time.Second
+
+	c := NewCollector()
+	for read := 1; read <= 2; read++ {
+		window, ok := c.averageWindow("GPU-0")
+		if !ok || window != 30*time.Second {
+			t.Errorf("read %d: window = %v, %v, want 30s, true", read, window, ok)
+		}
+	}
+}
+
+func TestAverageWindowForgottenDevice(t *testing.T) {
+	dev := &fakeDevice{uuid: "GPU-0", name: "Tesla T4", averageUtilization: 42}
+	lib := &fakeNVML{devices: []*fakeDevice{dev}}
+	c := NewCollector()
+	c.nvml = lib
+
+	c.update()
+	if len(dev.averageWindows) != 0 {
+		t.Fatalf("average utilization read on first update over %v", dev.averageWindows)
+	}
+	if got := len(collectSeries(c.utilizationAverage)); got != 0 {
+		t.Errorf("got %d average utilization series on first update, want 0", got)
+	}
+
+	c.update()
+	if len(dev.averageWindows) != 1 {
+		t.Fatalf("average utilization read %d times on second update, want once", len(dev.averageWindows))
+	}
+	if got := testutil.ToFloat64(c.utilizationAverage.WithLabelValues("0", "GPU-0", "Tesla T4")); got != 42 {
+		t.Errorf("average utilization = %v, want 42", got)
+	}
+
+	// A device that comes back is read like a new one.
+	lib.devices = nil
+	c.update()
+	if _, ok := c.readTimes["GPU-0"]; ok {
+		t.Error("read time of the removed device kept")
+	}
+	lib.devices = []*fakeDevice{dev}
+	c.update()
+	if len(dev.averageWindows) != 1 {
+		t.Errorf("average utilization read over %v on first update after the device came back", dev.averageWindows[1:])
+	}
+}
+
+func TestProcessUtilizationNoSamples(t *testing.T) {
+	dev := &fakeDevice{uuid: "GPU-0", name: "Tesla T4"}
+	c := NewCollector()
+	c.nvml = &fakeNVML{devices: []*fakeDevice{dev}}
+	c.update()
+
+	// Not Found only means that no process ran, it isn't counted as an error.
+	for _, m := range collectSeries(c.nvmlErrors) {
+		if code := seriesLabels(m)["code"]; code == "not_found" {
+			t.Errorf("no samples counted as %s error", code)
+		}
+	}
+	if got := len(collectSeries(c.utilizationSampleAge)); got != 0 {
+		t.Errorf("got %d utilization_sample_age_seconds series without samples, want 0", got)
+	}
+
+	dev.processUtilization = []gonvml.ProcessUtilizationSample{{TimeStamp: uint64(time.Now().UnixNano() / 1000)}}
+	c.update()
+	if got := len(collectSeries(c.utilizationSampleAge)); got != 1 {
+		t.Errorf("got %d utilization_sample_age_seconds series, want 1", got)
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	defer func(v bool) { *compatDutyCycle = v }(*compatDutyCycle)
+
+	for _, compat := range []bool{true, false} {
+		t.Run(fmt.Sprintf("metrics.compat-duty-cycle=%v", compat), func(t *testing.T) {
+			*compatDutyCycle = compat
+			c := NewCollector()
+			c.nvml = &fakeNVML{
+				devices: []*fakeDevice{{uuid: "GPU-0", name: "Tesla T4", utilization: 87, memoryUtilization: 35}},
+			}
+			c.update()
+
+			if got := testutil.ToFloat64(c.utilization.WithLabelValues("0", "GPU-0", "Tesla T4")); got != 87 {
+				t.Errorf("utilization_percent = %v, want 87", got)
+			}
+			if got := testutil.ToFloat64(c.memoryUtilization.WithLabelValues("0", "GPU-0", "Tesla T4")); got != 35 {
+				t.Errorf("memory_utilization_percent = %v, want 35", got)
+			}
+
+			series := collectSeries(c.dutyCycle)
+			if !compat {
+				if len(series) != 0 {
+					t.Errorf("got %d duty_cycle series, want none", len(series))
+				}
+				return
+			}
+			if len(series) != 1 || series[0].GetGauge().GetValue() != 87 {
+				t.Errorf("duty_cycle = %v, want a single series of 87", series)
+			}
+		})
+	}
+}
+
+func TestUtilizationHelp(t *testing.T) {
+	c := NewCollector()
+	for _, tt := range []struct {
+		vec  prometheus.Collector
+		name string
+		help string
+	}{
+		{c.utilization, "nvidia_gpu_utilization_percent", "Percent of time"},
+		{c.memoryUtilization, "nvidia_gpu_memory_utilization_percent", "Percent of time"},
+		{c.dutyCycle, "nvidia_gpu_duty_cycle", "Deprecated, use nvidia_gpu_utilization_percent."},
+		{c.migUtilization, "nvidia_gpu_mig_utilization_percent", "Percent of time"},
+	} {
+		ch := make(chan *prometheus.Desc, 1)
+		tt.vec.Describe(ch)
+		desc := (<-ch).String()
+		if !strings.Contains(desc, `fqName: "`+tt.name+`"`) || !strings.Contains(desc, `help: "`+tt.help) {
+			t.Errorf("got %s, want %s with help starting with %q", desc, tt.name, tt.help)
+		}
+	}
+}
+
+func TestDutyCycleHistogram(t *testing.T) {
+	defer func(v int) { *dutyCycleSamples = v }(*dutyCycleSamples)
+	defer func(v time.Duration) { *dutyCycleSampleInterval = v }(*dutyCycleSampleInterval)
+	defer func(v []float64) { utilizationBuckets = v }(utilizationBuckets)
+	*dutyCycleSamples = 3
+	*dutyCycleSampleInterval = 0
+	utilizationBuckets = []float64{50, 100}
+
+	dev := &fakeDevice{uuid: "GPU-0", name: "Tesla T4"}
+	c := NewCollector()
+	c.nvml = &fakeNVML{devices: []*fakeDevice{dev}}
+	// Probing the queries of a new device reads the utilization as well.
+	c.staticInfo(dev, 0, dev.uuid)
+	dev.utilizationSequence = []uint{20, 60, 90}
+	c.update()
+
+	if c.dutyCycle != nil {
+		t.Error("duty_cycle gauge created along with the histogram")
+	}
+	series := collectSeries(c.dutyCycleHistogram)
+	if len(series) != 1 {
+		t.Fatalf("got %d duty_cycle series, want 1", len(series))
+	}
+	h := series[0].GetHistogram()
+	if h.GetSampleCount() != 3 || h.GetSampleSum() != 170 {
+		t.Errorf("duty_cycle count = %d, sum = %v, want 3 samples summing to 170", h.GetSampleCount(), h.GetSampleSum())
+	}
+	if got := h.GetBucket()[0].GetCumulativeCount(); got != 1 {
+		t.Errorf("duty_cycle samples <= 50 = %d, want 1", got)
+	}
+	// The first sample is the utilization read for utilization_percent.
+	if got := testutil.ToFloat64(c.utilization.WithLabelValues("0", "GPU-0", "Tesla T4")); got != 20 {
+		t.Errorf("utilization_percent = %v, want 20", got)
+	}
+}
+
+func TestTotals(t *testing.T) {
+	for _, unit := range []string{"bytes", "mib"} {
+		t.Run(unit, func(t *testing.T) {
+			defer func(d float64) { memoryDivisor = d }(memoryDivisor)
+			var err error
+			memoryDivisor, err = parseMemoryUnit(unit)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lib := &fakeNVML{
+				devices: []*fakeDevice{
+					{
+						uuid:   "GPU-0",
+						memory: gonvml.MemoryInfoV2{Total: 16 << 30, Reserved: 300 << 20, Used: 5 << 30},
+						power:  70250,
+					},
+					{
+						minor:  1,
+						uuid:   "GPU-1",
+						memory: gonvml.MemoryInfoV2{Total: 16 << 30, Reserved: 300 << 20, Used: 1 << 20},
+						power:  31500,
+					},
+					{
+						minor:    2,
+						uuid:     "GPU-2",
+						memory:   gonvml.MemoryInfoV2{Total: 32 << 30, Reserved: 500 << 20, Used: 12345 << 10},
+						memoryV1: true,
+						power:    250000,
+					},
+				},
+			}
+			c := NewCollector()
+			c.nvml = lib
+			c.update()
+
+			if got, want := sumSeries(c.usedMemorySum), sumSeries(c.usedMemory); got != want {
+				t.Errorf("used memory total = %v, want sum of devices %v", got, want)
+			}
+			if got, want := sumSeries(c.totalMemorySum), sumSeries(c.totalMemory); got != want {
+				t.Errorf("memory total = %v, want sum of devices %v", got, want)
+			}
+			if got, want := sumSeries(c.totalMemorySum), memoryValue(64<<30); got != want {
+				t.Errorf("memory total = %v, want %v", got, want)
+			}
+			if got, want := sumSeries(c.powerUsageSum), 1000*sumSeries(c.powerUsageWatts); got != want {
+				t.Errorf("power usage total = %v mW, want sum of devices %v mW", got, want)
+			}
+		})
+	}
+}
+
+func TestMigModeEnabled(t *testing.T) {
+	c := NewCollector()
+	c.nvml = &fakeNVML{
+		devices: []*fakeDevice{
+			{uuid: "GPU-0", name: "A100", migModes: []bool{false, true}},
+			{minor: 1, uuid: "GPU-1", name: "Tesla T4"},
+		},
+	}
+	c.update()
+
+	for state, want := range map[string]float64{"current": 0, "pending": 1} {
+		if got := testutil.ToFloat64(c.migModeEnabled.WithLabelValues("0", "GPU-0", "A100", state)); got != want {
+			t.Errorf("mig_mode_enabled{state=%q} = %v, want %v", state, got, want)
+		}
+	}
+	// Devices without MIG support have no series.
+	if got := seriesUUIDs(c.migModeEnabled); len(got) != 1 || !got["GPU-0"] {
+		t.Errorf("mig_mode_enabled exported for %v, want GPU-0", got)
+	}
+}
+
+func TestExporterMetrics(t *testing.T) {
+	c := NewCollector()
+	c.nvml = &fakeNVML{devices: []*fakeDevice{{uuid: "GPU-0", name: "Tesla T4"}}}
+	if got := testutil.ToFloat64(c.lastSuccess); got != 0 {
+		t.Errorf("last success before an update = %v, want 0", got)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	for i := 0; i < 2; i++ {
+		if _, err := registry.Gather(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The updates on the scrapes don't reset the exporter's own metrics.
+	if got := testutil.ToFloat64(c.scrapes); got != 2 {
+		t.Errorf("scrapes = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(c.startTime); got == 0 {
+		t.Error("start time is not set")
+	}
+	if got := testutil.ToFloat64(c.lastSuccess); got == 0 {
+		t.Error("last success is not set")
+	}
+}
+
+func TestTemperature(t *testing.T) {
+	defer func(v bool) { *unlabeledTemperature = v }(*unlabeledTemperature)
+
+	for _, tt := range []struct {
+		unlabeled bool
+		// want maps the sensor label of each series to its value.
+		want map[string]float64
+	}{
+		// By default only the GPU sensor is exported, without the sensor
+		// label, like older versions did.
+		{true, map[string]float64{"": 45}},
+		{false, map[string]float64{"gpu": 45, "memory": 60}},
+	} {
+		*unlabeledTemperature = tt.unlabeled
+		c := NewCollector()
+		c.setTemperature("0", "GPU-0", "Tesla T4", "gpu", 45)
+		c.setTemperature("0", "GPU-0", "Tesla T4", "memory", 60)
+
+		got := make(map[string]float64)
+		for _, m := range collectSeries(c.temperature) {
+			got[seriesLabels(m)["sensor"]] = m.GetGauge().GetValue()
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("with unlabeled %v got temperatures %v, want %v", tt.unlabeled, got, tt.want)
+		}
+	}
+}
+
+func TestPowerSource(t *testing.T) {
+	battery := uint(1)
+	c := NewCollector()
+	c.nvml = &fakeNVML{
+		devices: []*fakeDevice{
+			{uuid: "GPU-0", name: "RTX 4090 Laptop GPU", powerSource: &battery},
+			{minor: 1, uuid: "GPU-1", name: "Tesla T4"},
+		},
+	}
+	c.update()
+
+	if got := testutil.ToFloat64(c.powerSource.WithLabelValues("0", "GPU-0", "RTX 4090 Laptop GPU")); got != 1 {
+		t.Errorf("power_source = %v, want 1 for battery", got)
+	}
+	// Devices that don't support the query have no series.
+	if got := seriesUUIDs(c.powerSource); len(got) != 1 || !got["GPU-0"] {
+		t.Errorf("power_source exported for %v, want GPU-0", got)
+	}
+}
+
+func TestVgpusUnsupportedPerDevice(t *testing.T) {
+	defer func(v bool) { *collectVgpus = v }(*collectVgpus)
+	*collectVgpus = true
+
+	host := &fakeDevice{uuid: "GPU-0", name: "A16", vgpuHost: true}
+	other := &fakeDevice{minor: 1, uuid: "GPU-1", name: "Tesla T4"}
+	c := NewCollector()
+	c.nvml = &fakeNVML{devices: []*fakeDevice{other, host}}
+	c.update()
+	c.update()
+
+	// The device without vGPU support doesn't stop the other device from
+	// being collected, and is only queried once.
+	if host.activeVgpusCalls != 2 || other.activeVgpusCalls != 1 {
+		t.Errorf("ActiveVgpus called %d and %d times, want 2 and 1", host.activeVgpusCalls, other.activeVgpusCalls)
+	}
+	if got := seriesUUIDs(c.vgpuInstances); len(got) != 1 || !got["GPU-0"] {
+		t.Errorf("vgpu_instances exported for %v, want GPU-0", got)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	memory := gonvml.MemoryInfoV2{Total: 16 << 30, Reserved: 300 << 20, Used: 5 << 30}
+	lib := &fakeNVML{
+		devices: []*fakeDevice{
+			{uuid: "GPU-0", name: "Tesla T4", memory: memory},
+			{minor: 1, uuid: "GPU-1", name: "Tesla T4", memory: memory, memoryV1: true},
+		},
+	}
+	c := NewCollector()
+	c.nvml = lib
+	c.update()
+
+	for _, labels := range [][]string{{"0", "GPU-0", "Tesla T4"}, {"1", "GPU-1", "Tesla T4"}} {
+		// Both queries count the reserved memory as used.
+		if got, want := testutil.ToFloat64(c.usedMemory.WithLabelValues(labels...)), float64(5<<30+300<<20); got != want {
+			t.Errorf("used memory of %s = %v, want %v", labels[1], got, want)
+		}
+		if got, want := testutil.ToFloat64(c.totalMemory.WithLabelValues(labels...)), float64(16<<30); got != want {
+			t.Errorf("total memory of %s = %v, want %v", labels[1], got, want)
+		}
+	}
+
+	// Only MemoryInfoV2 reports the reserved memory.
+	if got := seriesUUIDs(c.reservedMemory); len(got) != 1 || !got["GPU-0"] {
+		t.Errorf("reserved memory reported for %v, want GPU-0", got)
+	}
+	if got, want := testutil.ToFloat64(c.reservedMemory.WithLabelValues("0", "GPU-0", "Tesla T4")), float64(300<<20); got != want {
+		t.Errorf("reserved memory = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,870 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// vec is a metric vector of the collector.
+type vec interface {
+	prometheus.Collector
+	Delete(prometheus.Labels) bool
+}
+
+// metric declares a metric vector of the collector. Exactly one of gauge,
+// counter and histogram is set, pointing to the field of the collector that
+// holds the vector.
+type metric struct {
+	gauge     **prometheus.GaugeVec
+	counter   **prometheus.CounterVec
+	histogram **prometheus.HistogramVec
+	name      string
+	help      string
+	labels    []string
+
+	// Gauges are reset on every update unless keep is set. Counters and
+	// histograms are never reset.
+	keep bool
+
+	// Only used by histograms.
+	buckets []float64
+
+	// Set for amounts of memory, which are in the unit set with
+	// -collect.memory-unit. name and help have to be given in bytes.
+	memory bool
+}
+
+// memoryDivisor converts bytes to the unit set with -collect.memory-unit.
+var memoryDivisor float64 = 1
+
+// parseMemoryUnit returns the divisor that converts bytes to unit.
+func parseMemoryUnit(unit string) (float64, error) {
+	switch unit {
+	case "bytes":
+		return 1, nil
+	case "mib":
+		return 1 << 20, nil
+	}
+	return 0, fmt.Errorf("unknown memory unit %q, must be bytes or mib", unit)
+}
+
+// memoryValue converts an amount of memory in bytes to the unit set with
+// -collect.memory-unit.
+func memoryValue(bytes float64) float64 {
+	return bytes / memoryDivisor
+}
+
+// memoryUnitName returns the name and help of a memory metric given in bytes
+// for the unit set with -collect.memory-unit.
+func memoryUnitName(name, help string) (string, string) {
+	if memoryDivisor == 1 {
+		return name, help
+	}
+	return strings.TrimSuffix(name, "_bytes") + "_mebibytes", strings.TrimSuffix(help, " in bytes") + " in MiB"
+}
+
+// utilizationBuckets are the buckets of nvidia_gpu_utilization_samples_percent set
+// with -collect.utilization-buckets.
+var utilizationBuckets []float64
+
+// parseBuckets parses a comma separated list of bucket upper bounds.
+func parseBuckets(s string) ([]float64, error) {
+	var buckets []float64
+	for _, bucket := range strings.Split(s, ",") {
+		b, err := strconv.ParseFloat(strings.TrimSpace(bucket), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q: %v", bucket, err)
+		}
+		if len(buckets) > 0 && b <= buckets[len(buckets)-1] {
+			return nil, fmt.Errorf("buckets not in increasing order at %q", bucket)
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, nil
+}
+
+// metrics returns the metric vectors of the collector, which NewCollector
+// creates and Describe and Collect iterate over. The name is prefixed with the
+// namespace.
+func (c *Collector) metrics() []metric {
+	temperatureVecLabels := temperatureLabels
+	if *unlabeledTemperature {
+		temperatureVecLabels = labels
+	}
+	processMemoryLabels := processTypeLabels
+	if *processCmdline {
+		processMemoryLabels = processCommandLabels
+	}
+
+	// With -collect.duty-cycle-samples, nvidia_gpu_duty_cycle is a histogram
+	// and c.dutyCycle stays nil.
+	dutyCycle := metric{
+		gauge:  &c.dutyCycle,
+		name:   "duty_cycle",
+		help:   "Deprecated, use nvidia_gpu_utilization_percent. Percent of time over the past sample period during which one or more kernels were executing on the GPU device",
+		labels: labels,
+	}
+	if *dutyCycleSamples > 0 {
+		dutyCycle = metric{
+			histogram: &c.dutyCycleHistogram,
+			name:      "duty_cycle",
+			help:      "Utilization of the GPU device sampled several times on every read, see -collect.duty-cycle-samples",
+			labels:    labels,
+			buckets:   utilizationBuckets,
+		}
+	}
+
+	return []metric{
+		{
+			gauge: &c.startTime,
+			name:  "exporter_start_time_seconds",
+			help:  "Start time of the exporter since unix epoch in seconds",
+			keep:  true,
+		},
+		{
+			counter: &c.scrapes,
+			name:    "exporter_scrapes_total",
+			help:    "Number of times the exporter was scraped",
+		},
+		{
+			gauge: &c.lastSuccess,
+			name:  "exporter_last_success_timestamp_seconds",
+			help:  "Time the devices were last enumerated successfully since unix epoch in seconds",
+			keep:  true,
+		},
+		{
+			gauge: &c.numDevices,
+			name:  "num_devices",
+			help:  "Number of GPU devices",
+		},
+		{
+			gauge:  &c.usedMemorySum,
+			name:   "total_memory_used_bytes",
+			help:   "Sum of the memory used on all GPU devices in bytes",
+			memory: true,
+		},
+		{
+			gauge:  &c.totalMemorySum,
+			name:   "total_memory_bytes",
+			help:   "Sum of the total memory of all GPU devices in bytes",
+			memory: true,
+		},
+		{
+			gauge: &c.powerUsageSum,
+			name:  "total_power_usage_milliwatts",
+			help:  "Sum of the power usage of all GPU devices in milliwatts",
+		},
+		{
+			gauge: &c.excludedDevices,
+			name:  "excluded_devices",
+			help:  "Number of GPU devices excluded by the driver",
+		},
+		{
+			gauge:  &c.excludedDeviceInfo,
+			name:   "excluded_device_info",
+			help:   "Information about a GPU device excluded by the driver, always 1",
+			labels: excludedDeviceLabels,
+		},
+		{
+			gauge:  &c.deviceUp,
+			name:   "device_up",
+			help:   "Whether the GPU device could be queried (1 if up, 0 otherwise)",
+			labels: deviceUpLabels,
+		},
+		{
+			gauge:  &c.utilizationSampleAge,
+			name:   "utilization_sample_age_seconds",
+			help:   "Age of the most recent utilization sample of the GPU device in seconds",
+			labels: labels,
+		},
+		{
+			gauge:  &c.utilizationPeriod,
+			name:   "utilization_sample_period_seconds",
+			help:   "Period at which the driver samples the utilization of the GPU device in seconds",
+			labels: labels,
+		},
+		{
+			gauge:  &c.driverInfo,
+			name:   "driver_info",
+			help:   "Version of the driver and of NVML, always 1",
+			labels: []string{"driver_version", "nvml_version"},
+		},
+		{
+			gauge: &c.cudaDriverVersion,
+			name:  "cuda_driver_version",
+			help:  "Version of CUDA supported by the driver, encoded as 1000 * major + 10 * minor (e.g. 12040 for 12.4)",
+		},
+		{
+			gauge:  &c.usedMemory,
+			name:   "memory_used_bytes",
+			help:   "Memory used by the GPU device in bytes",
+			labels: labels,
+			memory: true,
+		},
+		{
+			gauge:  &c.totalMemory,
+			name:   "memory_total_bytes",
+			help:   "Total memory of the GPU device in bytes",
+			labels: labels,
+			memory: true,
+		},
+		{
+			gauge:  &c.reservedMemory,
+			name:   "memory_reserved_bytes",
+			help:   "Memory of the GPU device reserved by the driver and firmware, which is counted as used, in bytes",
+			labels: labels,
+			memory: true,
+		},
+		{
+			gauge:  &c.utilization,
+			name:   "utilization_percent",
+			help:   "Percent of time over the past sample period during which one or more kernels were executing on the GPU device",
+			labels: labels,
+		},
+		{
+			gauge:  &c.memoryUtilization,
+			name:   "memory_utilization_percent",
+			help:   "Percent of time over the past sample period during which memory of the GPU device was being read or written",
+			labels: labels,
+		},
+		dutyCycle,
+		{
+			histogram: &c.utilizationHistogram,
+			name:      "utilization_samples_percent",
+			help:      "Utilization samples of the GPU device taken by the driver, see -collect.utilization-histogram",
+			labels:    labels,
+			buckets:   utilizationBuckets,
+		},
+		{
+			gauge:  &c.utilizationAverage,
+			name:   "utilization_average_percent",
+			help:   "Percent of time over the window during which one or more kernels were executing on the GPU device, see -collector.utilization.window",
+			labels: labels,
+		},
+		{
+			gauge:  &c.powerUsage,
+			name:   "power_usage_milliwatts",
+			help:   "Power usage of the GPU device in milliwatts",
+			labels: labels,
+		},
+		{
+			gauge:  &c.powerUsageWatts,
+			name:   "power_usage_watts",
+			help:   "Power usage of the GPU device in watts",
+			labels: labels,
+		},
+		{
+			gauge:  &c.powerUsageAverage,
+			name:   "power_usage_average_milliwatts",
+			help:   "Power usage of the GPU device averaged over the window in milliwatts, see -collector.utilization.window",
+			labels: labels,
+		},
+		{
+			gauge:  &c.powerUsageMax,
+			name:   "power_usage_max_milliwatts",
+			help:   "Maximum power usage of the GPU device sampled by the driver since the device was last read in milliwatts",
+			labels: labels,
+		},
+		{
+			gauge:  &c.temperature,
+			name:   "temperature_celsius",
+			help:   "Temperature of the GPU device by sensor in celsius",
+			labels: temperatureVecLabels,
+		},
+		{
+			gauge:  &c.fanSpeed,
+			name:   "fanspeed_percent",
+			help:   "Fanspeed of the GPU device as a percent of its maximum",
+			labels: labels,
+		},
+		{
+			gauge:  &c.powerLimitMin,
+			name:   "power_limit_min_milliwatts",
+			help:   "Minimum power management limit that can be set on the GPU device in milliwatts",
+			labels: labels,
+		},
+		{
+			gauge:  &c.powerLimitMax,
+			name:   "power_limit_max_milliwatts",
+			help:   "Maximum power management limit that can be set on the GPU device in milliwatts",
+			labels: labels,
+		},
+		{
+			gauge:  &c.powerLimitDefault,
+			name:   "power_limit_default_milliwatts",
+			help:   "Default power management limit of the GPU device in milliwatts",
+			labels: labels,
+		},
+		{
+			gauge:  &c.powerLimitEnforced,
+			name:   "power_limit_enforced_milliwatts",
+			help:   "Power management limit enforced on the GPU device in milliwatts",
+			labels: labels,
+		},
+		{
+			gauge:  &c.thermalSensorTarget,
+			name:   "thermal_sensor_target_celsius",
+			help:   "Temperature of the target the thermal sensor of the GPU device measures in degrees Celsius",
+			labels: thermalSensorLabels,
+		},
+		{
+			gauge:  &c.thermalSensorDefaultMin,
+			name:   "thermal_sensor_default_min_celsius",
+			help:   "Default minimum temperature of the thermal sensor of the GPU device in degrees Celsius",
+			labels: thermalSensorLabels,
+		},
+		{
+			gauge:  &c.thermalSensorDefaultMax,
+			name:   "thermal_sensor_default_max_celsius",
+			help:   "Default maximum temperature of the thermal sensor of the GPU device in degrees Celsius",
+			labels: thermalSensorLabels,
+		},
+		{
+			gauge:  &c.temperatureHeadroom,
+			name:   "temperature_headroom_celsius",
+			help:   "Difference between the shutdown temperature and the current temperature of the GPU device in celsius",
+			labels: labels,
+		},
+		{
+			gauge: &c.confComputeEnabled,
+			name:  "confidential_compute_enabled",
+			help:  "Whether confidential computing is enabled (1 if enabled, 0 otherwise)",
+		},
+		{
+			gauge: &c.confComputeDevTools,
+			name:  "confidential_compute_devtools_enabled",
+			help:  "Whether confidential computing runs in devtools mode (1 if enabled, 0 otherwise)",
+		},
+		{
+			gauge: &c.confComputeReady,
+			name:  "confidential_compute_ready",
+			help:  "Whether the GPU devices accept work in confidential computing mode (1 if ready, 0 otherwise)",
+		},
+		{
+			gauge:  &c.deviceInfo,
+			name:   "device_info",
+			help:   "Information about the GPU device, always 1",
+			labels: infoLabels,
+		},
+		{
+			gauge:  &c.applicationsClockIsDefault,
+			name:   "applications_clock_is_default",
+			help:   "Whether the applications clocks of the GPU device are set to their defaults (1 if they are, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.powerState,
+			name:   "power_state",
+			help:   "Power state of the GPU device, from 0 (maximum performance) to 15 (minimum performance)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.powerSource,
+			name:   "power_source",
+			help:   "Power source of the GPU device (0 for AC, 1 for battery, 2 for an undersized power supply)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.memoryTemperature,
+			name:   "memory_temperature_celsius",
+			help:   "Temperature of the GPU device memory in celsius",
+			labels: labels,
+		},
+		{
+			gauge:  &c.inforomInfo,
+			name:   "inforom_info",
+			help:   "Versions of the InfoROM of the GPU device, always 1",
+			labels: inforomLabels,
+		},
+		{
+			gauge:  &c.inforomConfigValid,
+			name:   "inforom_config_valid",
+			help:   "Whether the checksum of the InfoROM of the GPU device is valid (1 if it is, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.querySupported,
+			name:   "query_supported",
+			help:   "Whether the GPU device supports the query (1 if it does, 0 otherwise), probed when the device is first seen",
+			labels: querySupportedLabels,
+		},
+		{
+			gauge:  &c.multiGPUBoard,
+			name:   "multi_gpu_board",
+			help:   "Whether the GPU device is on a multi-GPU board (1 if it is, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.boardID,
+			name:   "board_id",
+			help:   "Identifier of the board the GPU device is on; devices on the same multi-GPU board share it",
+			labels: labels,
+		},
+		{
+			gauge:  &c.computeCapability,
+			name:   "cuda_compute_capability_info",
+			help:   "CUDA compute capability of the GPU device as major and minor version, always 1",
+			labels: computeCapabilityLabels,
+		},
+		{
+			gauge:  &c.multiprocessors,
+			name:   "multiprocessors",
+			help:   "Number of streaming multiprocessors of the GPU device",
+			labels: labels,
+		},
+		{
+			gauge:  &c.cudaCores,
+			name:   "cuda_cores",
+			help:   "Number of CUDA cores of the GPU device",
+			labels: labels,
+		},
+		{
+			gauge:  &c.numaNode,
+			name:   "numa_node",
+			help:   "NUMA node the GPU device is attached to, -1 if it has no NUMA affinity",
+			labels: labels,
+		},
+		{
+			gauge:  &c.virtualizationMode,
+			name:   "virtualization_mode",
+			help:   "Virtualization mode of the GPU device (0: none, 1: passthrough, 2: vGPU, 3: host vGPU, 4: host vSGA)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.hostVgpuMode,
+			name:   "host_vgpu_mode",
+			help:   "Mode in which the GPU device hosts vGPUs (0: non-SR-IOV, 1: SR-IOV)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.operatingMode,
+			name:   "operating_mode",
+			help:   "Operating mode of the GPU device (all_on for graphics and compute, compute, or low_dp), always 1",
+			labels: operatingModeLabels,
+		},
+		{
+			gauge:  &c.driverModelCurrent,
+			name:   "driver_model_current",
+			help:   "Current driver model of the GPU device on Windows (wddm, tcc or mcdm), always 1",
+			labels: driverModelLabels,
+		},
+		{
+			gauge:  &c.driverModelPending,
+			name:   "driver_model_pending",
+			help:   "Driver model of the GPU device on Windows after the next reboot (wddm, tcc or mcdm), always 1",
+			labels: driverModelLabels,
+		},
+		{
+			gauge:  &c.eccModeEnabled,
+			name:   "ecc_mode_enabled",
+			help:   "Whether ECC is enabled on the GPU device (1 if enabled, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.eccModePendingEnabled,
+			name:   "ecc_mode_pending_enabled",
+			help:   "Whether ECC will be enabled on the GPU device after the next reboot (1 if enabled, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.remappedRowsCorrectable,
+			name:   "remapped_rows_correctable",
+			help:   "Number of memory rows of the GPU device remapped due to correctable errors",
+			labels: labels,
+		},
+		{
+			gauge:  &c.remappedRowsUncorrectable,
+			name:   "remapped_rows_uncorrectable",
+			help:   "Number of memory rows of the GPU device remapped due to uncorrectable errors",
+			labels: labels,
+		},
+		{
+			gauge:  &c.remappedRowsPending,
+			name:   "remapped_rows_pending",
+			help:   "Whether a remapping of memory rows of the GPU device is pending until the next reset (1 if pending, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.remappedRowsFailure,
+			name:   "remapped_rows_failure",
+			help:   "Whether remapping memory rows of the GPU device failed (1 if failed, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.resetRequired,
+			name:   "reset_required",
+			help:   "Whether the GPU device has to be reset before it can be used again (1 if required, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.recoveryAction,
+			name:   "recovery_action",
+			help:   "Action recommended by the driver to recover the GPU device, always 1",
+			labels: recoveryActionLabels,
+		},
+		{
+			gauge:  &c.gspFirmwareEnabled,
+			name:   "gsp_firmware_enabled",
+			help:   "Whether the GPU device runs with GSP firmware (1 if enabled, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.gspFirmwareDefaultEnabled,
+			name:   "gsp_firmware_default_enabled",
+			help:   "Whether the GPU device runs with GSP firmware by default (1 if enabled, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.migModeCurrent,
+			name:   "mig_mode_current",
+			help:   "Whether MIG mode is currently enabled on the GPU device (1 if enabled, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.migModePending,
+			name:   "mig_mode_pending",
+			help:   "Whether MIG mode will be enabled on the GPU device after the next reset (1 if enabled, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.migModeEnabled,
+			name:   "mig_mode_enabled",
+			help:   "Whether MIG mode is enabled on the GPU device, currently or after the next reset by state (1 if enabled, 0 otherwise)",
+			labels: migModeLabels,
+		},
+		{
+			gauge:  &c.migUsedMemory,
+			name:   "mig_memory_used_bytes",
+			help:   "Memory used by the MIG device in bytes",
+			labels: migLabels,
+			memory: true,
+		},
+		{
+			gauge:  &c.migTotalMemory,
+			name:   "mig_memory_total_bytes",
+			help:   "Total memory of the MIG device in bytes",
+			labels: migLabels,
+			memory: true,
+		},
+		{
+			gauge:  &c.migInfo,
+			name:   "mig_instance_info",
+			help:   "Information about the MIG device, always 1",
+			labels: migInfoLabels,
+		},
+		{
+			gauge:  &c.migUtilization,
+			name:   "mig_utilization_percent",
+			help:   "Percent of time over the past sample period during which one or more kernels were executing on the MIG device",
+			labels: migLabels,
+		},
+		{
+			gauge:  &c.displayActive,
+			name:   "display_active",
+			help:   "Whether a display is initialized on the GPU device (1 if it is, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.displayMode,
+			name:   "display_mode",
+			help:   "Whether a physical display is connected to the GPU device (1 if it is, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.attachedDisplays,
+			name:   "attached_displays",
+			help:   "Whether physical displays are attached to the GPU device (1 if any are, 0 otherwise, including devices without display outputs)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.clockOffset,
+			name:   "clock_offset_hertz",
+			help:   "Offset applied to the clock of the GPU device in the performance state in hertz",
+			labels: clockOffsetLabels,
+		},
+		{
+			gauge:  &c.clockMaxCustomerBoost,
+			name:   "clock_max_customer_boost_hertz",
+			help:   "Maximum customer boost clock of the GPU device in hertz",
+			labels: clockLabels,
+		},
+		{
+			gauge:  &c.encoderSessions,
+			name:   "encoder_sessions",
+			help:   "Number of active encoder sessions on the GPU device",
+			labels: labels,
+		},
+		{
+			gauge:  &c.encoderAverageLatency,
+			name:   "encoder_average_latency_microseconds",
+			help:   "Average latency of the active encoder sessions on the GPU device in microseconds",
+			labels: labels,
+		},
+		{
+			counter: &c.clockThrottleThermal,
+			name:    "clock_throttle_thermal_us_total",
+			help:    "Time the clocks of the GPU device were reduced due to thermal constraints in microseconds",
+			labels:  labels,
+		},
+		{
+			counter: &c.clockThrottlePower,
+			name:    "clock_throttle_power_us_total",
+			help:    "Time the clocks of the GPU device were reduced due to power constraints in microseconds",
+			labels:  labels,
+		},
+		{
+			gauge:  &c.nvlinkActive,
+			name:   "nvlink_active",
+			help:   "Whether the NVLink link of the GPU device is active (1 if active, 0 otherwise)",
+			labels: nvlinkLabels,
+		},
+		{
+			gauge:  &c.nvlinkRemote,
+			name:   "nvlink_remote_info",
+			help:   "What the NVLink link of the GPU device is connected to, always 1",
+			labels: nvlinkRemoteLabels,
+		},
+		{
+			counter: &c.nvlinkTx,
+			name:    "nvlink_tx_bytes_total",
+			help:    "Data transmitted over the NVLink link of the GPU device in bytes",
+			labels:  nvlinkLabels,
+		},
+		{
+			counter: &c.nvlinkRx,
+			name:    "nvlink_rx_bytes_total",
+			help:    "Data received over the NVLink link of the GPU device in bytes",
+			labels:  nvlinkLabels,
+		},
+		{
+			counter: &c.nvlinkErrors,
+			name:    "nvlink_errors_total",
+			help:    "Number of errors on the NVLink link of the GPU device by type",
+			labels:  nvlinkErrorLabels,
+		},
+		{
+			gauge:  &c.fabricState,
+			name:   "fabric_state",
+			help:   "State of the registration of the GPU device with the NVLink fabric (1 not started, 2 in progress, 3 completed)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.fabricStatus,
+			name:   "fabric_status",
+			help:   "Result of the registration of the GPU device with the NVLink fabric, always 1",
+			labels: fabricStatusLabels,
+		},
+		{
+			gauge:  &c.fabricInfo,
+			name:   "fabric_info",
+			help:   "NVLink fabric the GPU device is registered with, always 1",
+			labels: fabricInfoLabels,
+		},
+		{
+			gauge:  &c.c2cLinks,
+			name:   "c2c_links",
+			help:   "Number of C2C links between the GPU device and the CPU",
+			labels: labels,
+		},
+		{
+			gauge:  &c.c2cActive,
+			name:   "c2c_active",
+			help:   "Whether the C2C links of the GPU device are active (1 if active, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.c2cMaxBandwidth,
+			name:   "c2c_link_max_bandwidth_bytes_per_second",
+			help:   "Maximum bandwidth of the C2C link of the GPU device in bytes per second",
+			labels: nvlinkLabels,
+		},
+		{
+			gauge:  &c.processCount,
+			name:   "running_process_count",
+			help:   "Number of processes having a compute context on the GPU device",
+			labels: labels,
+		},
+		{
+			gauge:  &c.processMemory,
+			name:   "process_memory_used_bytes",
+			help:   "Memory used by the process on the GPU device in bytes",
+			labels: processMemoryLabels,
+			memory: true,
+		},
+		{
+			gauge:  &c.vgpuInstances,
+			name:   "vgpu_instances",
+			help:   "Number of vGPU instances running on the GPU device",
+			labels: labels,
+		},
+		{
+			gauge:  &c.vgpuUsedMemory,
+			name:   "vgpu_memory_used_bytes",
+			help:   "Framebuffer memory used by the vGPU instance in bytes",
+			labels: vgpuLabels,
+			memory: true,
+		},
+		{
+			gauge:  &c.licenseStatus,
+			name:   "license_status",
+			help:   "Whether the vGPU product is licensed on the GPU device (1 if licensed, 0 otherwise)",
+			labels: licenseLabels,
+		},
+		{
+			gauge:  &c.licenseExpiry,
+			name:   "license_expiry_timestamp_seconds",
+			help:   "Expiry time of the vGPU product license on the GPU device since unix epoch in seconds",
+			labels: licenseLabels,
+		},
+		{
+			counter: &c.nvmlErrors,
+			name:    "nvml_errors_total",
+			help:    "Number of failed NVML queries by NVML return code",
+			labels:  []string{"code"},
+		},
+		{
+			gauge:  &c.permissionDenied,
+			name:   "permission_denied",
+			help:   "Whether the query failed due to insufficient permissions (1 if it did)",
+			labels: []string{"query"},
+			// Set once per query, see queryFailed.
+			keep: true,
+		},
+		{
+			counter: &c.queryRetries,
+			name:    "query_retries_total",
+			help:    "Number of times a query was retried after failing with an unknown error",
+			labels:  []string{"query"},
+		},
+		{
+			counter: &c.xidErrors,
+			name:    "xid_errors_total",
+			help:    "Number of critical XID errors reported for the GPU device since the exporter started",
+			labels:  xidLabels,
+		},
+		{
+			counter: &c.eccDBEEvents,
+			name:    "ecc_dbe_events_total",
+			help:    "Number of double bit ECC errors reported for the GPU device since the exporter started",
+			labels:  labels,
+		},
+		{
+			gauge:  &c.fieldValue,
+			name:   "field_value",
+			help:   "Value of the NVML field of the GPU device, see -collector.fields",
+			labels: fieldLabels,
+		},
+		{
+			gauge:  &c.unitInfo,
+			name:   "unit_info",
+			help:   "Information about the unit, always 1",
+			labels: unitInfoLabels,
+		},
+		{
+			gauge:  &c.unitPSUState,
+			name:   "unit_psu_state",
+			help:   "State of the PSU of the unit as reported by the driver, always 1",
+			labels: unitPSUStateLabels,
+		},
+		{
+			gauge:  &c.unitPSUCurrent,
+			name:   "unit_psu_current_amperes",
+			help:   "Current drawn by the PSU of the unit in amperes",
+			labels: unitLabels,
+		},
+		{
+			gauge:  &c.unitPSUVoltage,
+			name:   "unit_psu_voltage_volts",
+			help:   "Voltage of the PSU of the unit in volts",
+			labels: unitLabels,
+		},
+		{
+			gauge:  &c.unitPSUPower,
+			name:   "unit_psu_power_watts",
+			help:   "Power drawn by the PSU of the unit in watts",
+			labels: unitLabels,
+		},
+		{
+			gauge:  &c.unitFanSpeed,
+			name:   "unit_fan_speed_rpm",
+			help:   "Speed of the fan of the unit in RPM",
+			labels: unitFanLabels,
+		},
+		{
+			gauge:  &c.unitFanFailed,
+			name:   "unit_fan_failed",
+			help:   "Whether the fan of the unit failed (1 if failed, 0 otherwise)",
+			labels: unitFanLabels,
+		},
+		{
+			gauge:  &c.unitTemperature,
+			name:   "unit_temperature_celsius",
+			help:   "Temperature of the unit by sensor in celsius",
+			labels: unitTemperatureLabels,
+		},
+		{
+			gauge:  &c.smActive,
+			name:   "sm_active_ratio",
+			help:   "Ratio of time the SMs of the GPU device were busy since the previous update",
+			labels: labels,
+		},
+		{
+			gauge:  &c.smOccupancy,
+			name:   "sm_occupancy_ratio",
+			help:   "Ratio of warps resident on the SMs of the GPU device to the maximum since the previous update",
+			labels: labels,
+		},
+		{
+			gauge:  &c.tensorActive,
+			name:   "tensor_active_ratio",
+			help:   "Ratio of time the tensor cores of the GPU device were busy since the previous update",
+			labels: labels,
+		},
+		{
+			gauge:  &c.dramActive,
+			name:   "dram_active_ratio",
+			help:   "Ratio of the memory bandwidth of the GPU device used since the previous update",
+			labels: labels,
+		},
+		{
+			gauge:  &c.accountingEnabled,
+			name:   "accounting_enabled",
+			help:   "Whether accounting mode is enabled on the GPU device (1 if enabled, 0 otherwise)",
+			labels: labels,
+		},
+		{
+			gauge:  &c.accountingBufferSize,
+			name:   "accounting_buffer_size",
+			help:   "Number of processes the driver keeps accounting statistics for on the GPU device",
+			labels: labels,
+		},
+		{
+			gauge:  &c.accountingMaxMemory,
+			name:   "accounting_process_max_memory_bytes",
+			help:   "Maximum memory used by the process on the GPU device in bytes",
+			labels: processLabels,
+			memory: true,
+		},
+		{
+			gauge:  &c.accountingGPUUtilization,
+			name:   "accounting_process_gpu_utilization_percent",
+			help:   "Percent of time over the process's lifetime during which one or more of its kernels were executing on the GPU device",
+			labels: processLabels,
+		},
+		{
+			gauge:  &c.accountingMemoryUtilization,
+			name:   "accounting_process_memory_utilization_percent",
+			help:   "Percent of time over the process's lifetime during which its memory was being read or written on the GPU device",
+			labels: processLabels,
+		},
+		{
+			gauge:  &c.accountingTime,
+			name:   "accounting_process_time_ms",
+			help:   "Time the process has been running on the GPU device in milliseconds",
+			labels: processLabels,
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xofym/gonvml"
+)
+
+// collectMig reads the metrics of the MIG devices of a device that has MIG
+// mode enabled. The MIG devices are enumerated on every call so that instances
+// created or destroyed at runtime are picked up.
+func (c *Collector) collectMig(dev nvmlDevice, i int, minor, uuid, name string) {
+	count, err := dev.MaxMigDeviceCount()
+	if err != nil {
+		c.queryFailed(err, i, "MaxMigDeviceCount")
+		return
+	}
+
+	for j := uint(0); j < count; j++ {
+		mig, err := dev.MigDeviceHandleByIndex(j)
+		// Not every possible MIG device exists.
+		if isNVMLError(err, gonvml.ErrorNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Uint("mig_index", j).
+				Msg("Cannot get MigDeviceHandleByIndex")
+			c.countError(err)
+			continue
+		}
+
+		gpuInstanceID, err := mig.GpuInstanceID()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Uint("mig_index", j).
+				Msg("Cannot get MIG device GpuInstanceID")
+			c.countError(err)
+			continue
+		}
+
+		computeInstanceID, err := mig.ComputeInstanceID()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Uint("mig_index", j).
+				Msg("Cannot get MIG device ComputeInstanceID")
+			c.countError(err)
+			continue
+		}
+
+		migName, err := mig.Name()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Uint("mig_index", j).
+				Msg("Cannot get MIG device Name")
+			c.countError(err)
+			continue
+		}
+
+		migUUID, err := mig.UUID()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Uint("mig_index", j).
+				Msg("Cannot get MIG device UUID")
+			c.countError(err)
+			continue
+		}
+
+		gi := strconv.FormatUint(uint64(gpuInstanceID), 10)
+		ci := strconv.FormatUint(uint64(computeInstanceID), 10)
+		profile := migProfile(migName)
+		c.migInfo.WithLabelValues(minor, uuid, name, gi, ci, profile, migUUID).Set(1)
+
+		totalMemory, usedMemory, err := mig.MemoryInfo()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Uint("mig_index", j).
+				Msg("Cannot get MIG device MemoryInfo")
+			c.countError(err)
+		} else {
+			c.migUsedMemory.WithLabelValues(minor, uuid, name, gi, ci, profile).Set(memoryValue(float64(usedMemory)))
+			c.migTotalMemory.WithLabelValues(minor, uuid, name, gi, ci, profile).Set(memoryValue(float64(totalMemory)))
+		}
+
+		// Not every driver reports utilization for MIG devices.
+		utilization, _, err := mig.UtilizationRates()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Uint("mig_index", j).
+				Msg("Cannot get MIG device UtilizationRates")
+			c.countError(err)
+		} else {
+			c.migUtilization.WithLabelValues(minor, uuid, name, gi, ci, profile).Set(float64(utilization))
+		}
+	}
+}
+
+// migProfile returns the profile of a MIG device, e.g. "1g.5gb", from its
+// name, e.g. "NVIDIA A100-SXM4-40GB MIG 1g.5gb".
+func migProfile(name string) string {
+	if i := strings.LastIndex(name, "MIG "); i >= 0 {
+		return name[i+len("MIG "):]
+	}
+	return ""
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"time"
+
+	"github.com/xofym/gonvml"
+)
+
+// nvmlLibrary is the part of the NVML API the collector queries outside of
+// devices. gonvmlLibrary implements it with the gonvml bindings; tests replace
+// it with a fake.
+type nvmlLibrary interface {
+	SystemDriverVersion() (string, error)
+	SystemNVMLVersion() (string, error)
+	SystemCudaDriverVersion() (int, error)
+	SystemConfComputeState() (gonvml.ConfComputeState, error)
+	SystemConfComputeGpusReadyState() (bool, error)
+	ExcludedDeviceCount() (uint, error)
+	ExcludedDeviceInfoByIndex(idx uint) (gonvml.ExcludedDeviceInfo, error)
+	UnitCount() (uint, error)
+	UnitHandleByIndex(idx uint) (nvmlUnit, error)
+	DeviceCount() (uint, error)
+	DeviceHandleByIndex(idx uint) (nvmlDevice, error)
+	NewEventSet() (nvmlEventSet, error)
+	GpmSampleAlloc() (gonvml.GpmSample, error)
+	GpmMetricsGet(sample1, sample2 gonvml.GpmSample, metricIDs []gonvml.GpmMetricID) ([]gonvml.GpmMetric, error)
+}
+
+// nvmlDevice is the part of the API of gonvml.Device the collector uses.
+type nvmlDevice interface {
+	MinorNumber() (uint, error)
+	UUID() (string, error)
+	Name() (string, error)
+
+	Serial() (string, error)
+	VbiosVersion() (string, error)
+	PCIInfo() (gonvml.PCIInfo, error)
+	BoardPartNumber() (string, error)
+	Brand() (uint, error)
+	Architecture() (uint, error)
+	MultiGPUBoard() (bool, error)
+	BoardID() (uint, error)
+	IrqNum() (uint, error)
+	NumaNodeID() (uint, error)
+	CudaComputeCapability() (int, int, error)
+	Attributes() (gonvml.DeviceAttributes, error)
+	NumGpuCores() (uint, error)
+	InforomVersion(object uint) (string, error)
+	InforomImageVersion() (string, error)
+	ValidateInforom() error
+
+	MemoryInfo() (uint64, uint64, error)
+	MemoryInfoV2() (gonvml.MemoryInfoV2, error)
+	UtilizationRates() (uint, uint, error)
+	AverageGPUUtilization(since time.Duration) (uint, error)
+	ProcessUtilization(lastSeenTimeStamp uint64) ([]gonvml.ProcessUtilizationSample, error)
+	Samples(samplingType gonvml.SamplingType, lastSeenTimeStamp uint64) ([]gonvml.Sample, error)
+	PowerUsage() (uint, error)
+	AveragePowerUsage(since time.Duration) (uint, error)
+	PowerManagementLimitConstraints() (uint, uint, error)
+	PowerManagementDefaultLimit() (uint, error)
+	EnforcedPowerLimit() (uint, error)
+	PowerState() (uint, error)
+	PowerSource() (uint, error)
+	Temperature() (uint, error)
+	MemoryTemperature() (uint, error)
+	HotspotTemperature() (uint, error)
+	TemperatureThreshold(t gonvml.TemperatureThreshold) (uint, error)
+	ThermalSettings() (gonvml.ThermalSettings, error)
+	FanSpeed() (uint, error)
+	ViolationStatus(policy gonvml.PerfPolicyType) (referenceTime, violationTime uint64, err error)
+	EncoderStats() (sessionCount, averageFps, averageLatency uint, err error)
+	ApplicationsClock(clockType gonvml.ClockType) (uint, error)
+	DefaultApplicationsClock(clockType gonvml.ClockType) (uint, error)
+	MaxCustomerBoostClock(clockType gonvml.ClockType) (uint, error)
+	SupportedPerformanceStates() ([]uint, error)
+	ClockOffsets(clockType gonvml.ClockType, pstate uint) (gonvml.ClockOffset, error)
+
+	EccMode() (current, pending bool, err error)
+	RemappedRows() (corrRows, uncRows uint, isPending, failureOccurred bool, err error)
+	FieldValues(fieldIDs []uint) ([]gonvml.FieldValue, error)
+	GpuOperationMode() (current, pending uint, err error)
+	DriverModel() (current, pending uint, err error)
+	GspFirmwareMode() (enabled, defaultEnabled bool, err error)
+	DisplayActive() (bool, error)
+	DisplayMode() (bool, error)
+	VirtualizationMode() (uint, error)
+	HostVgpuMode() (uint, error)
+
+	NvLinkState(link uint) (bool, error)
+	NvLinkThroughput(link uint) (uint64, uint64, error)
+	NvLinkErrorCounter(link uint, counter gonvml.NvLinkErrorCounter) (uint64, error)
+	NvLinkRemotePCIInfo(link uint) (gonvml.PCIInfo, error)
+	NvLinkRemoteDeviceType(link uint) (uint, error)
+	C2CLinkCount() (uint, error)
+	C2CLinkStatus() (bool, error)
+	C2CLinkMaxBandwidth(link uint) (uint, error)
+	GpuFabricInfo() (gonvml.GpuFabricInfo, error)
+
+	ComputeRunningProcesses() ([]gonvml.ProcessInfo, error)
+	GraphicsRunningProcesses() ([]gonvml.ProcessInfo, error)
+	AccountingMode() (bool, error)
+	AccountingBufferSize() (uint, error)
+	AccountingPids() ([]uint, error)
+	AccountingStats(pid uint) (gonvml.AccountingStats, error)
+
+	MigMode() (bool, bool, error)
+	MaxMigDeviceCount() (uint, error)
+	MigDeviceHandleByIndex(idx uint) (nvmlDevice, error)
+	GpuInstanceID() (uint, error)
+	ComputeInstanceID() (uint, error)
+	ActiveVgpus() ([]gonvml.VgpuInstance, error)
+	GridLicensableFeatures() ([]gonvml.GridLicensableFeature, error)
+
+	GpmQueryDeviceSupport() (bool, error)
+	GpmSampleGet(sample gonvml.GpmSample) error
+
+	SupportedEventTypes() (uint64, error)
+	RegisterEvents(eventTypes uint64, set nvmlEventSet) error
+}
+
+// nvmlEventSet is a set of devices registered for events, see
+// gonvml.EventSet.
+type nvmlEventSet interface {
+	Wait(timeout time.Duration) (nvmlEvent, error)
+	Free() error
+}
+
+// nvmlEvent is an event delivered to an nvmlEventSet, see gonvml.EventData.
+type nvmlEvent struct {
+	Device    nvmlDevice
+	EventType uint64
+	EventData uint64
+}
+
+// nvmlUnit is an S-class unit, see gonvml.Unit.
+type nvmlUnit interface {
+	Info() (gonvml.UnitInfo, error)
+	PsuInfo() (gonvml.PSUInfo, error)
+	FanSpeedInfo() ([]gonvml.UnitFanInfo, error)
+	Temperature(sensor gonvml.UnitTemperatureType) (uint, error)
+}
+
+// gonvmlLibrary implements nvmlLibrary with the gonvml bindings.
+type gonvmlLibrary struct{}
+
+func (gonvmlLibrary) SystemDriverVersion() (string, error) { return gonvml.SystemDriverVersion() }
+func (gonvmlLibrary) SystemNVMLVersion() (string, error)   { return gonvml.SystemNVMLVersion() }
+func (gonvmlLibrary) SystemCudaDriverVersion() (int, error) {
+	return gonvml.SystemCudaDriverVersion()
+}
+
+func (gonvmlLibrary) SystemConfComputeState() (gonvml.ConfComputeState, error) {
+	return gonvml.SystemConfComputeState()
+}
+
+func (gonvmlLibrary) SystemConfComputeGpusReadyState() (bool, error) {
+	return gonvml.SystemConfComputeGpusReadyState()
+}
+
+func (gonvmlLibrary) ExcludedDeviceCount() (uint, error) { return gonvml.ExcludedDeviceCount() }
+func (gonvmlLibrary) ExcludedDeviceInfoByIndex(idx uint) (gonvml.ExcludedDeviceInfo, error) {
+	return gonvml.ExcludedDeviceInfoByIndex(idx)
+}
+
+func (gonvmlLibrary) UnitCount() (uint, error) { return gonvml.UnitCount() }
+func (gonvmlLibrary) UnitHandleByIndex(idx uint) (nvmlUnit, error) {
+	return gonvml.UnitHandleByIndex(idx)
+}
+
+func (gonvmlLibrary) DeviceCount() (uint, error) { return gonvml.DeviceCount() }
+func (gonvmlLibrary) DeviceHandleByIndex(idx uint) (nvmlDevice, error) {
+	dev, err := gonvml.DeviceHandleByIndex(idx)
+	return gonvmlDevice{dev}, err
+}
+
+func (gonvmlLibrary) NewEventSet() (nvmlEventSet, error) {
+	set, err := gonvml.NewEventSet()
+	return gonvmlEventSet{set}, err
+}
+
+func (gonvmlLibrary) GpmSampleAlloc() (gonvml.GpmSample, error) { return gonvml.GpmSampleAlloc() }
+func (gonvmlLibrary) GpmMetricsGet(sample1, sample2 gonvml.GpmSample, metricIDs []gonvml.GpmMetricID) ([]gonvml.GpmMetric, error) {
+	return gonvml.GpmMetricsGet(sample1, sample2, metricIDs)
+}
+
+// gonvmlDevice implements nvmlDevice with a gonvml.Device. Only the methods
+// that take or return devices and event sets need wrapping.
+type gonvmlDevice struct {
+	gonvml.Device
+}
+
+func (d gonvmlDevice) MigDeviceHandleByIndex(idx uint) (nvmlDevice, error) {
+	mig, err := d.Device.MigDeviceHandleByIndex(idx)
+	return gonvmlDevice{mig}, err
+}
+
+func (d gonvmlDevice) RegisterEvents(eventTypes uint64, set nvmlEventSet) error {
+	return d.Device.RegisterEvents(eventTypes, set.(gonvmlEventSet).EventSet)
+}
+
+// gonvmlEventSet implements nvmlEventSet with a gonvml.EventSet.
+type gonvmlEventSet struct {
+	gonvml.EventSet
+}
+
+func (s gonvmlEventSet) Wait(timeout time.Duration) (nvmlEvent, error) {
+	event, err := s.EventSet.Wait(timeout)
+	return nvmlEvent{
+		Device:    gonvmlDevice{event.Device},
+		EventType: event.EventType,
+		EventData: event.EventData,
+	}, err
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"time"
+
+	"github.com/xofym/gonvml"
+)
+
+// The fakes fail the queries a test doesn't set up with errNotSupported, like
+// NVML does for queries the device doesn't support.
+var (
+	errNotSupported     = &gonvml.Error{Return: gonvml.ErrorNotSupported, Message: "Not Supported"}
+	errTimeout          = &gonvml.Error{Return: gonvml.ErrorTimeout, Message: "Timeout"}
+	errNotFound         = &gonvml.Error{Return: gonvml.ErrorNotFound, Message: "Not Found"}
+	errInvalidArgument  = &gonvml.Error{Return: gonvml.ErrorInvalidArgument, Message: "Invalid Argument"}
+	errFunctionNotFound = &gonvml.Error{Return: gonvml.ErrorFunctionNotFound, Message: "Function Not Found"}
+)
+
+// fakeNVML implements nvmlLibrary for tests.
+type fakeNVML struct {
+	driverVersion     string
+	nvmlVersion       string
+	cudaDriverVersion int
+	devices           []*fakeDevice
+
+	// eventSets receives every event set created with NewEventSet.
+	eventSets chan *fakeEventSet
+}
+
+func (l *fakeNVML) SystemDriverVersion() (string, error) {
+	if l.driverVersion == "" {
+		return "", errNotSupported
+	}
+	return l.driverVersion, nil
+}
+
+func (l *fakeNVML) SystemNVMLVersion() (string, error) {
+	if l.nvmlVersion == "" {
+		return "", errNotSupported
+	}
+	return l.nvmlVersion, nil
+}
+
+func (l *fakeNVML) SystemCudaDriverVersion() (int, error) {
+	return l.cudaDriverVersion, nil
+}
+
+func (l *fakeNVML) SystemConfComputeState() (gonvml.ConfComputeState, error) {
+	return gonvml.ConfComputeState{}, errNotSupported
+}
+
+func (l *fakeNVML) SystemConfComputeGpusReadyState() (bool, error) {
+	return false, errNotSupported
+}
+
+func (l *fakeNVML) ExcludedDeviceCount() (uint, error) { return 0, nil }
+func (l *fakeNVML) ExcludedDeviceInfoByIndex(idx uint) (gonvml.ExcludedDeviceInfo, error) {
+	return gonvml.ExcludedDeviceInfo{}, errNotSupported
+}
+
+func (l *fakeNVML) UnitCount() (uint, error) { return 0, nil }
+func (l *fakeNVML) UnitHandleByIndex(idx uint) (nvmlUnit, error) {
+	return nil, errNotSupported
+}
+
+func (l *fakeNVML) DeviceCount() (uint, error) { return uint(len(l.devices)), nil }
+func (l *fakeNVML) DeviceHandleByIndex(idx uint) (nvmlDevice, error) {
+	if int(idx) >= len(l.devices) {
+		return nil, errInvalidArgument
+	}
+	return l.devices[idx], nil
+}
+
+func (l *fakeNVML) NewEventSet() (nvmlEventSet, error) {
+	set := &fakeEventSet{waits: make(chan fakeWait)}
+	if l.eventSets != nil {
+		l.eventSets <- set
+	}
+	return set, nil
+}
+
+func (l *fakeNVML) GpmSampleAlloc() (gonvml.GpmSample, error) {
+	return gonvml.GpmSample{}, errNotSupported
+}
+
+func (l *fakeNVML) GpmMetricsGet(sample1, sample2 gonvml.GpmSample, metricIDs []gonvml.GpmMetricID) ([]gonvml.GpmMetric, error) {
+	return nil, errNotSupported
+}
+
+// fakeDevice implements nvmlDevice for tests. Queries it doesn't override
+// are not supported.
+type fakeDevice struct {
+	unsupportedDevice
+
+	minor uint
+	uuid  string
+	name  string
+	// noMinor makes MinorNumber fail with Not Supported, like it does in
+	// vGPU guests.
+	noMinor bool
+
+	// memory is returned by MemoryInfoV2 and, with the reserved memory
+	// counted as used, by MemoryInfo. With memoryV1 only MemoryInfo is
+	// supported, like with older drivers.
+	memory   gonvml.MemoryInfoV2
+	memoryV1 bool
+	// power is the power usage in mW.
+	power uint
+	// pciBusID is returned by PCIInfo if set.
+	pciBusID string
+	// migModes are the current and pending MIG mode returned by MigMode if
+	// set.
+	migModes []bool
+	// powerSource is returned by PowerSource if set.
+	powerSource *uint
+
+	// thermal is returned by ThermalSettings if set.
+	thermal *gonvml.ThermalSettings
+
+	// violationTime is the cumulative time in ns the clocks were reduced
+	// for any reason.
+	violationTime uint64
+	// processUtilization are the samples returned by ProcessUtilization,
+	// which fails with Not Found without any, like NVML does.
+	processUtilization []gonvml.ProcessUtilizationSample
+	// utilizationSamples are the samples in the driver's utilization buffer.
+	utilizationSamples []gonvml.Sample
+	// utilization and memoryUtilization are returned by UtilizationRates.
+	utilization       uint
+	memoryUtilization uint
+	// utilizationSequence, if set, is returned by UtilizationRates in turn
+	// instead of utilization.
+	utilizationSequence []uint
+	// averageUtilization is returned by AverageGPUUtilization, which records
+	// the windows it is called with in averageWindows.
+	averageUtilization uint
+	averageWindows     []time.Duration
+
+	// supportedEvents are the event types the device supports; registered
+	// records the event sets it was registered with.
+	supportedEvents uint64
+	registered      []nvmlEventSet
+
+	// fields are returned by FieldValues by field ID. Other fields are not
+	// supported. The IDs of each call are recorded in fieldRequests.
+	fields        map[uint]gonvml.FieldValue
+	fieldRequests [][]uint
+
+	// inforomValidations counts the calls to ValidateInforom.
+	inforomValidations int
+
+	// vgpuHost makes ActiveVgpus report no instances instead of failing as
+	// not supported; activeVgpusCalls counts its calls.
+	vgpuHost         bool
+	activeVgpusCalls int
+
+	// c2cLinkCountCalls counts the calls to C2CLinkCount, which isn't
+	// supported.
+	c2cLinkCountCalls int
+}
+
+func (d *fakeDevice) MinorNumber() (uint, error) {
+	if d.noMinor {
+		return 0, errNotSupported
+	}
+	return d.minor, nil
+}
+
+func (d *fakeDevice) UUID() (string, error) { return d.uuid, nil }
+func (d *fakeDevice) Name() (string, error) { return d.name, nil }
+
+func (d *fakeDevice) MemoryInfo() (uint64, uint64, error) {
+	return d.memory.Total, d.memory.Used + d.memory.Reserved, nil
+}
+
+func (d *fakeDevice) MemoryInfoV2() (gonvml.MemoryInfoV2, error) {
+	if d.memoryV1 {
+		return gonvml.MemoryInfoV2{}, errFunctionNotFound
+	}
+	return d.memory, nil
+}
+
+func (d *fakeDevice) PowerUsage() (uint, error) { return d.power, nil }
+
+func (d *fakeDevice) MigMode() (bool, bool, error) {
+	if d.migModes == nil {
+		return false, false, errNotSupported
+	}
+	return d.migModes[0], d.migModes[1], nil
+}
+
+func (d *fakeDevice) PowerSource() (uint, error) {
+	if d.powerSource == nil {
+		return 0, errNotSupported
+	}
+	return *d.powerSource, nil
+}
+
+func (d *fakeDevice) PCIInfo() (gonvml.PCIInfo, error) {
+	if d.pciBusID == "" {
+		return gonvml.PCIInfo{}, errNotSupported
+	}
+	return gonvml.PCIInfo{BusID: d.pciBusID}, nil
+}
+
+func (d *fakeDevice) ThermalSettings() (gonvml.ThermalSettings, error) {
+	if d.thermal == nil {
+		return gonvml.ThermalSettings{}, errNotSupported
+	}
+	return *d.thermal, nil
+}
+
+func (d *fakeDevice) ViolationStatus(policy gonvml.PerfPolicyType) (uint64, uint64, error) {
+	return 0, d.violationTime, nil
+}
+
+func (d *fakeDevice) UtilizationRates() (uint, uint, error) {
+	if len(d.utilizationSequence) > 0 {
+		utilization := d.utilizationSequence[0]
+		d.utilizationSequence = d.utilizationSequence[1:]
+		return utilization, d.memoryUtilization, nil
+	}
+	return d.utilization, d.memoryUtilization, nil
+}
+
+func (d *fakeDevice) AverageGPUUtilization(since time.Duration) (uint, error) {
+	d.averageWindows = append(d.averageWindows, since)
+	return d.averageUtilization, nil
+}
+
+func (d *fakeDevice) Samples(samplingType gonvml.SamplingType, lastSeenTimeStamp uint64) ([]gonvml.Sample, error) {
+	if samplingType != gonvml.SamplingTypeGPUUtilization {
+		return nil, errNotSupported
+	}
+	var samples []gonvml.Sample
+	for _, sample := range d.utilizationSamples {
+		if sample.TimeStamp > lastSeenTimeStamp {
+			samples = append(samples, sample)
+		}
+	}
+	if len(samples) == 0 {
+		return nil, errNotFound
+	}
+	return samples, nil
+}
+
+func (d *fakeDevice) ActiveVgpus() ([]gonvml.VgpuInstance, error) {
+	d.activeVgpusCalls++
+	if !d.vgpuHost {
+		return nil, errNotSupported
+	}
+	return nil, nil
+}
+
+func (d *fakeDevice) C2CLinkCount() (uint, error) {
+	d.c2cLinkCountCalls++
+	return 0, errNotSupported
+}
+
+func (d *fakeDevice) ValidateInforom() error {
+	d.inforomValidations++
+	return nil
+}
+
+func (d *fakeDevice) ProcessUtilization(lastSeenTimeStamp uint64) ([]gonvml.ProcessUtilizationSample, error) {
+	if len(d.processUtilization) == 0 {
+		return nil, errNotFound
+	}
+	return d.processUtilization, nil
+}
+
+func (d *fakeDevice) SupportedEventTypes() (uint64, error) {
+	if d.supportedEvents == 0 {
+		return 0, errNotSupported
+	}
+	return d.supportedEvents, nil
+}
+
+func (d *fakeDevice) RegisterEvents(eventTypes uint64, set nvmlEventSet) error {
+	d.registered = append(d.registered, set)
+	return nil
+}
+
+func (d *fakeDevice) FieldValues(fieldIDs []uint) ([]gonvml.FieldValue, error) {
+	d.fieldRequests = append(d.fieldRequests, append([]uint(nil), fieldIDs...))
+	values := make([]gonvml.FieldValue, len(fieldIDs))
+	for i, id := range fieldIDs {
+		v, ok := d.fields[id]
+		if !ok {
+			v = gonvml.FieldValue{Err: errNotSupported}
+		}
+		v.FieldID = id
+		values[i] = v
+	}
+	return values, nil
+}
+
+// fakeEventSet implements nvmlEventSet for tests. Wait returns what is sent
+// on waits, or times out right away if nothing is.
+type fakeEventSet struct {
+	waits chan fakeWait
+	freed bool
+}
+
+// fakeWait is the result of a call to fakeEventSet.Wait.
+type fakeWait struct {
+	event nvmlEvent
+	err   error
+}
+
+func (s *fakeEventSet) Wait(timeout time.Duration) (nvmlEvent, error) {
+	select {
+	case w := <-s.waits:
+		return w.event, w.err
+	case <-time.After(time.Millisecond):
+		return nvmlEvent{}, errTimeout
+	}
+}
+
+func (s *fakeEventSet) Free() error {
+	s.freed = true
+	return nil
+}
+
+// unsupportedDevice implements nvmlDevice with queries that are all not
+// supported.
+type unsupportedDevice struct{}
+
+func (unsupportedDevice) MinorNumber() (uint, error) { return 0, errNotSupported }
+func (unsupportedDevice) UUID() (string, error)      { return "", errNotSupported }
+func (unsupportedDevice) Name() (string, error)      { return "", errNotSupported }
+
+func (unsupportedDevice) Serial() (string, error)                  { return "", errNotSupported }
+func (unsupportedDevice) VbiosVersion() (string, error)            { return "", errNotSupported }
+func (unsupportedDevice) PCIInfo() (gonvml.PCIInfo, error)         { return gonvml.PCIInfo{}, errNotSupported }
+func (unsupportedDevice) BoardPartNumber() (string, error)         { return "", errNotSupported }
+func (unsupportedDevice) Brand() (uint, error)                     { return 0, errNotSupported }
+func (unsupportedDevice) Architecture() (uint, error)              { return 0, errNotSupported }
+func (unsupportedDevice) MultiGPUBoard() (bool, error)             { return false, errNotSupported }
+func (unsupportedDevice) BoardID() (uint, error)                   { return 0, errNotSupported }
+func (unsupportedDevice) IrqNum() (uint, error)                    { return 0, errNotSupported }
+func (unsupportedDevice) NumaNodeID() (uint, error)                { return 0, errNotSupported }
+func (unsupportedDevice) CudaComputeCapability() (int, int, error) { return 0, 0, errNotSupported }
+func (unsupportedDevice) Attributes() (gonvml.DeviceAttributes, error) {
+	return gonvml.DeviceAttributes{}, errNotSupported
+}
+func (unsupportedDevice) NumGpuCores() (uint, error)           { return 0, errNotSupported }
+func (unsupportedDevice) InforomVersion(uint) (string, error)  { return "", errNotSupported }
+func (unsupportedDevice) InforomImageVersion() (string, error) { return "", errNotSupported }
+func (unsupportedDevice) ValidateInforom() error               { return errNotSupported }
+
+func (unsupportedDevice) MemoryInfo() (uint64, uint64, error) { return 0, 0, errNotSupported }
+func (unsupportedDevice) MemoryInfoV2() (gonvml.MemoryInfoV2, error) {
+	return gonvml.MemoryInfoV2{}, errNotSupported
+}
+func (unsupportedDevice) UtilizationRates() (uint, uint, error) { return 0, 0, errNotSupported }
+func (unsupportedDevice) AverageGPUUtilization(time.Duration) (uint, error) {
+	return 0, errNotSupported
+}
+func (unsupportedDevice) ProcessUtilization(uint64) ([]gonvml.ProcessUtilizationSample, error) {
+	return nil, errNotSupported
+}
+func (unsupportedDevice) Samples(gonvml.SamplingType, uint64) ([]gonvml.Sample, error) {
+	return nil, errNotSupported
+}
+func (unsupportedDevice) PowerUsage() (uint, error)                     { return 0, errNotSupported }
+func (unsupportedDevice) AveragePowerUsage(time.Duration) (uint, error) { return 0, errNotSupported }
+func (unsupportedDevice) PowerManagementLimitConstraints() (uint, uint, error) {
+	return 0, 0, errNotSupported
+}
+func (unsupportedDevice) PowerManagementDefaultLimit() (uint, error) { return 0, errNotSupported }
+func (unsupportedDevice) EnforcedPowerLimit() (uint, error)          { return 0, errNotSupported }
+func (unsupportedDevice) PowerState() (uint, error)                  { return 0, errNotSupported }
+func (unsupportedDevice) PowerSource() (uint, error)                 { return 0, errNotSupported }
+func (unsupportedDevice) Temperature() (uint, error)                 { return 0, errNotSupported }
+func (unsupportedDevice) MemoryTemperature() (uint, error)           { return 0, errNotSupported }
+func (unsupportedDevice) HotspotTemperature() (uint, error)          { return 0, errNotSupported }
+func (unsupportedDevice) TemperatureThreshold(gonvml.TemperatureThreshold) (uint, error) {
+	return 0, errNotSupported
+}
+func (unsupportedDevice) ThermalSettings() (gonvml.ThermalSettings, error) {
+	return gonvml.ThermalSettings{}, errNotSupported
+}
+func (unsupportedDevice) FanSpeed() (uint, error) { return 0, errNotSupported }
+func (unsupportedDevice) ViolationStatus(gonvml.PerfPolicyType) (uint64, uint64, error) {
+	return 0, 0, errNotSupported
+}
+func (unsupportedDevice) EncoderStats() (uint, uint, uint, error)          { return 0, 0, 0, errNotSupported }
+func (unsupportedDevice) ApplicationsClock(gonvml.ClockType) (uint, error) { return 0, errNotSupported }
+func (unsupportedDevice) DefaultApplicationsClock(gonvml.ClockType) (uint, error) {
+	return 0, errNotSupported
+}
+func (unsupportedDevice) MaxCustomerBoostClock(gonvml.ClockType) (uint, error) {
+	return 0, errNotSupported
+}
+func (unsupportedDevice) SupportedPerformanceStates() ([]uint, error) { return nil, errNotSupported }
+func (unsupportedDevice) ClockOffsets(gonvml.ClockType, uint) (gonvml.ClockOffset, error) {
+	return gonvml.ClockOffset{}, errNotSupported
+}
+
+func (unsupportedDevice) EccMode() (bool, bool, error) { return false, false, errNotSupported }
+func (unsupportedDevice) RemappedRows() (uint, uint, bool, bool, error) {
+	return 0, 0, false, false, errNotSupported
+}
+func (unsupportedDevice) FieldValues([]uint) ([]gonvml.FieldValue, error) {
+	return nil, errNotSupported
+}
+func (unsupportedDevice) GpuOperationMode() (uint, uint, error) { return 0, 0, errNotSupported }
+func (unsupportedDevice) DriverModel() (uint, uint, error)      { return 0, 0, errNotSupported }
+func (unsupportedDevice) GspFirmwareMode() (bool, bool, error)  { return false, false, errNotSupported }
+func (unsupportedDevice) DisplayActive() (bool, error)          { return false, errNotSupported }
+func (unsupportedDevice) DisplayMode() (bool, error)            { return false, errNotSupported }
+func (unsupportedDevice) VirtualizationMode() (uint, error)     { return 0, errNotSupported }
+func (unsupportedDevice) HostVgpuMode() (uint, error)           { return 0, errNotSupported }
+
+func (unsupportedDevice) NvLinkState(uint) (bool, error)                { return false, errNotSupported }
+func (unsupportedDevice) NvLinkThroughput(uint) (uint64, uint64, error) { return 0, 0, errNotSupported }
+func (unsupportedDevice) NvLinkErrorCounter(uint, gonvml.NvLinkErrorCounter) (uint64, error) {
+	return 0, errNotSupported
+}
+func (unsupportedDevice) NvLinkRemotePCIInfo(uint) (gonvml.PCIInfo, error) {
+	return gonvml.PCIInfo{}, errNotSupported
+}
+func (unsupportedDevice) NvLinkRemoteDeviceType(uint) (uint, error) { return 0, errNotSupported }
+func (unsupportedDevice) C2CLinkCount() (uint, error)               { return 0, errNotSupported }
+func (unsupportedDevice) C2CLinkStatus() (bool, error)              { return false, errNotSupported }
+func (unsupportedDevice) C2CLinkMaxBandwidth(uint) (uint, error)    { return 0, errNotSupported }
+func (unsupportedDevice) GpuFabricInfo() (gonvml.GpuFabricInfo, error) {
+	return gonvml.GpuFabricInfo{}, errNotSupported
+}
+
+func (unsupportedDevice) ComputeRunningProcesses() ([]gonvml.ProcessInfo, error) {
+	return nil, errNotSupported
+}
+func (unsupportedDevice) GraphicsRunningProcesses() ([]gonvml.ProcessInfo, error) {
+	return nil, errNotSupported
+}
+func (unsupportedDevice) AccountingMode() (bool, error)       { return false, errNotSupported }
+func (unsupportedDevice) AccountingBufferSize() (uint, error) { return 0, errNotSupported }
+func (unsupportedDevice) AccountingPids() ([]uint, error)     { return nil, errNotSupported }
+func (unsupportedDevice) AccountingStats(uint) (gonvml.AccountingStats, error) {
+	return gonvml.AccountingStats{}, errNotSupported
+}
+
+func (unsupportedDevice) MigMode() (bool, bool, error)     { return false, false, errNotSupported }
+func (unsupportedDevice) MaxMigDeviceCount() (uint, error) { return 0, errNotSupported }
+func (unsupportedDevice) MigDeviceHandleByIndex(uint) (nvmlDevice, error) {
+	return nil, errNotSupported
+}
+func (unsupportedDevice) GpuInstanceID() (uint, error)                { return 0, errNotSupported }
+func (unsupportedDevice) ComputeInstanceID() (uint, error)            { return 0, errNotSupported }
+func (unsupportedDevice) ActiveVgpus() ([]gonvml.VgpuInstance, error) { return nil, errNotSupported }
+func (unsupportedDevice) GridLicensableFeatures() ([]gonvml.GridLicensableFeature, error) {
+	return nil, errNotSupported
+}
+
+func (unsupportedDevice) GpmQueryDeviceSupport() (bool, error) { return false, errNotSupported }
+func (unsupportedDevice) GpmSampleGet(gonvml.GpmSample) error  { return errNotSupported }
+
+func (unsupportedDevice) SupportedEventTypes() (uint64, error)      { return 0, errNotSupported }
+func (unsupportedDevice) RegisterEvents(uint64, nvmlEventSet) error { return errNotSupported }
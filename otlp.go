@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog/log"
+)
+
+// The OTLP metrics are pushed as JSON over HTTP, see
+// https://opentelemetry.io/docs/specs/otlp/#otlphttp, which doesn't need the
+// OpenTelemetry SDK. Only the parts of the data model the exporter uses are
+// declared. 64 bit integers are encoded as strings, like protojson does.
+
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+	Summary     *otlpSummary   `json:"summary,omitempty"`
+}
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE, the temporality of
+// Prometheus counters and histograms.
+const otlpCumulative = 2
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpSummary struct {
+	DataPoints []otlpSummaryDataPoint `json:"dataPoints"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+	TimeUnixNano uint64          `json:"timeUnixNano,string"`
+	AsDouble     otlpDouble      `json:"asDouble"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes     []otlpAttribute `json:"attributes,omitempty"`
+	TimeUnixNano   uint64          `json:"timeUnixNano,string"`
+	Count          uint64          `json:"count,string"`
+	Sum            otlpDouble      `json:"sum"`
+	BucketCounts   []string        `json:"bucketCounts"`
+	ExplicitBounds []otlpDouble    `json:"explicitBounds,omitempty"`
+}
+
+type otlpSummaryDataPoint struct {
+	Attributes     []otlpAttribute     `json:"attributes,omitempty"`
+	TimeUnixNano   uint64              `json:"timeUnixNano,string"`
+	Count          uint64              `json:"count,string"`
+	Sum            otlpDouble          `json:"sum"`
+	QuantileValues []otlpQuantileValue `json:"quantileValues"`
+}
+
+type otlpQuantileValue struct {
+	Quantile otlpDouble `json:"quantile"`
+	Value    otlpDouble `json:"value"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// otlpDouble is a float64 that encodes NaN and the infinities as strings,
+// which encoding/json refuses to encode as numbers.
+type otlpDouble float64
+
+func (d otlpDouble) MarshalJSON() ([]byte, error) {
+	f := float64(d)
+	switch {
+	case math.IsNaN(f):
+		return []byte(`"NaN"`), nil
+	case math.IsInf(f, 1):
+		return []byte(`"Infinity"`), nil
+	case math.IsInf(f, -1):
+		return []byte(`"-Infinity"`), nil
+	}
+	return json.Marshal(f)
+}
+
+// runOTLP pushes the metrics gathered from gatherer to endpoint every
+// interval. The collector reads the devices in the background on the same
+// interval, so the pushed values are the most recently read ones and no
+// extra queries are made.
+func runOTLP(endpoint string, interval time.Duration, gatherer prometheus.Gatherer) {
+	client := &http.Client{Timeout: interval}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if err := pushOTLP(client, endpoint, gatherer, now); err != nil {
+			log.Error().
+				Err(err).
+				Str("endpoint", endpoint).
+				Msg("Cannot push metrics over OTLP")
+		}
+	}
+}
+
+// pushOTLP pushes the metrics gathered from gatherer to endpoint, with the
+// data points timestamped now.
+func pushOTLP(client *http.Client, endpoint string, gatherer prometheus.Gatherer, now time.Time) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(newOTLPRequest(families, now))
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	return err
+}
+
+// newOTLPRequest converts families to an OTLP export request. Prometheus
+// counters become monotonic cumulative sums and untyped metrics gauges.
+func newOTLPRequest(families []*dto.MetricFamily, now time.Time) otlpRequest {
+	ts := uint64(now.UnixNano())
+	var metrics []otlpMetric
+	for _, family := range families {
+		m := otlpMetric{
+			Name:        family.GetName(),
+			Description: family.GetHelp(),
+		}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			m.Sum = &otlpSum{
+				AggregationTemporality: otlpCumulative,
+				IsMonotonic:            true,
+			}
+			for _, s := range family.GetMetric() {
+				m.Sum.DataPoints = append(m.Sum.DataPoints, otlpNumberDataPoint{
+					Attributes:   otlpAttributes(s.GetLabel()),
+					TimeUnixNano: ts,
+					AsDouble:     otlpDouble(s.GetCounter().GetValue()),
+				})
+			}
+		case dto.MetricType_HISTOGRAM:
+			m.Histogram = &otlpHistogram{AggregationTemporality: otlpCumulative}
+			for _, s := range family.GetMetric() {
+				m.Histogram.DataPoints = append(m.Histogram.DataPoints, otlpHistogramPoint(s, ts))
+			}
+		case dto.MetricType_SUMMARY:
+			m.Summary = &otlpSummary{}
+			for _, s := range family.GetMetric() {
+				p := otlpSummaryDataPoint{
+					Attributes:   otlpAttributes(s.GetLabel()),
+					TimeUnixNano: ts,
+					Count:        s.GetSummary().GetSampleCount(),
+					Sum:          otlpDouble(s.GetSummary().GetSampleSum()),
+				}
+				for _, q := range s.GetSummary().GetQuantile() {
+					p.QuantileValues = append(p.QuantileValues, otlpQuantileValue{
+						Quantile: otlpDouble(q.GetQuantile()),
+						Value:    otlpDouble(q.GetValue()),
+					})
+				}
+				m.Summary.DataPoints = append(m.Summary.DataPoints, p)
+			}
+		default:
+			m.Gauge = &otlpGauge{}
+			for _, s := range family.GetMetric() {
+				value := s.GetGauge().GetValue()
+				if family.GetType() == dto.MetricType_UNTYPED {
+					value = s.GetUntyped().GetValue()
+				}
+				m.Gauge.DataPoints = append(m.Gauge.DataPoints, otlpNumberDataPoint{
+					Attributes:   otlpAttributes(s.GetLabel()),
+					TimeUnixNano: ts,
+					AsDouble:     otlpDouble(value),
+				})
+			}
+		}
+		metrics = append(metrics, m)
+	}
+
+	resource := []otlpAttribute{otlpAttr("service.name", "nvidia_gpu_prometheus_exporter")}
+	if hostname, err := os.Hostname(); err == nil {
+		resource = append(resource, otlpAttr("host.name", hostname))
+	}
+	return otlpRequest{
+		ResourceMetrics: []otlpResourceMetrics{{
+			Resource: otlpResource{Attributes: resource},
+			ScopeMetrics: []otlpScopeMetrics{{
+				Scope:   otlpScope{Name: "nvidia_gpu_prometheus_exporter"},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+// otlpHistogramPoint converts a Prometheus histogram, whose buckets are
+// cumulative, to an OTLP data point, whose bucket counts are not and end with
+// the count above the last bound.
+func otlpHistogramPoint(s *dto.Metric, ts uint64) otlpHistogramDataPoint {
+	h := s.GetHistogram()
+	p := otlpHistogramDataPoint{
+		Attributes:   otlpAttributes(s.GetLabel()),
+		TimeUnixNano: ts,
+		Count:        h.GetSampleCount(),
+		Sum:          otlpDouble(h.GetSampleSum()),
+	}
+	var below uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+		p.ExplicitBounds = append(p.ExplicitBounds, otlpDouble(b.GetUpperBound()))
+		p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(b.GetCumulativeCount()-below, 10))
+		below = b.GetCumulativeCount()
+	}
+	p.BucketCounts = append(p.BucketCounts, strconv.FormatUint(h.GetSampleCount()-below, 10))
+	return p
+}
+
+func otlpAttributes(labels []*dto.LabelPair) []otlpAttribute {
+	var attributes []otlpAttribute
+	for _, l := range labels {
+		attributes = append(attributes, otlpAttr(l.GetName(), l.GetValue()))
+	}
+	return attributes
+}
+
+func otlpAttr(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAnyValue{StringValue: value}}
+}
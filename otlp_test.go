@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPushOTLP(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	temperature := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "nvidia_gpu_temperature_celsius",
+		Help: "Temperature of the GPU device in celsius",
+	}, labels)
+	temperature.WithLabelValues("0", "GPU-0", "Tesla T4").Set(42)
+	xids := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "nvidia_gpu_xid_errors_total",
+		Help: "Number of critical XID errors",
+	})
+	xids.Add(3)
+	samples := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "nvidia_gpu_utilization_samples_percent",
+		Help:    "Utilization samples",
+		Buckets: []float64{50, 100},
+	})
+	for _, v := range []float64{10, 60, 70, 150} {
+		samples.Observe(v)
+	}
+	registry.MustRegister(temperature, xids, samples)
+
+	var (
+		contentType string
+		req         struct {
+			ResourceMetrics []struct {
+				ScopeMetrics []struct {
+					Metrics []map[string]json.RawMessage
+				}
+			}
+		}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("cannot decode request: %v\n%s", err, body)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Unix(1700000000, 0)
+	if err := pushOTLP(server.Client(), server.URL, registry, now); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	if len(req.ResourceMetrics) != 1 || len(req.ResourceMetrics[0].ScopeMetrics) != 1 {
+		t.Fatalf("got %+v, want one resource with one scope", req)
+	}
+
+	got := make(map[string]string)
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		var name string
+		if err := json.Unmarshal(m["name"], &name); err != nil {
+			t.Fatal(err)
+		}
+		for kind, data := range m {
+			if kind != "name" && kind != "description" {
+				got[name] = kind + " " + string(data)
+			}
+		}
+	}
+	want := map[string]string{
+		"nvidia_gpu_temperature_celsius": `gauge {"dataPoints":[{"attributes":[` +
+			`{"key":"minor_number","value":{"stringValue":"0"}},` +
+			`{"key":"name","value":{"stringValue":"Tesla T4"}},` +
+			`{"key":"uuid","value":{"stringValue":"GPU-0"}}],` +
+			`"timeUnixNano":"1700000000000000000","asDouble":42}]}`,
+		"nvidia_gpu_xid_errors_total": `sum {"dataPoints":[{"timeUnixNano":"1700000000000000000","asDouble":3}],` +
+			`"aggregationTemporality":2,"isMonotonic":true}`,
+		"nvidia_gpu_utilization_samples_percent": `histogram {"dataPoints":[{"timeUnixNano":"1700000000000000000",` +
+			`"count":"4","sum":290,"bucketCounts":["1","2","1"],"explicitBounds":[50,100]}],"aggregationTemporality":2}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got metrics\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestPushOTLPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown path", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	err := pushOTLP(server.Client(), server.URL, prometheus.NewRegistry(), time.Now())
+	if err == nil || !strings.Contains(err.Error(), "unknown path") {
+		t.Errorf("got error %v, want the response of the endpoint", err)
+	}
+}
+
+func TestOTLPDouble(t *testing.T) {
+	for _, tt := range []struct {
+		value float64
+		want  string
+	}{
+		{1.5, `1.5`},
+		{math.NaN(), `"NaN"`},
+		{math.Inf(1), `"Infinity"`},
+		{math.Inf(-1), `"-Infinity"`},
+	} {
+		got, err := json.Marshal(otlpDouble(tt.value))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("otlpDouble(%v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"time"
+
+	"github.com/xofym/gonvml"
+)
+
+// query is an NVML query the exporter makes, named like in the logs of failed
+// queries. It queries either the system or a device.
+type query struct {
+	name   string
+	system func(nvmlLibrary) error
+	device func(nvmlDevice) error
+
+	// The value of the query label of nvidia_gpu_query_supported if the
+	// support of the query is probed, see probedQueries.
+	probe string
+}
+
+// queries are the queries update and staticInfo make, with the arguments
+// they are made with or, for queries made per link, sensor or pstate, the
+// first one. Queries that need a handle returned by another query, e.g. of a
+// MIG device, a unit, an event set or a GPM sample, are left out.
+var queries = []query{
+	{name: "SystemDriverVersion", system: func(nvml nvmlLibrary) error { _, err := nvml.SystemDriverVersion(); return err }},
+	{name: "SystemNVMLVersion", system: func(nvml nvmlLibrary) error { _, err := nvml.SystemNVMLVersion(); return err }},
+	{name: "SystemCudaDriverVersion", system: func(nvml nvmlLibrary) error { _, err := nvml.SystemCudaDriverVersion(); return err }},
+	{name: "SystemConfComputeState", system: func(nvml nvmlLibrary) error { _, err := nvml.SystemConfComputeState(); return err }},
+	{name: "SystemConfComputeGpusReadyState", system: func(nvml nvmlLibrary) error { _, err := nvml.SystemConfComputeGpusReadyState(); return err }},
+	{name: "ExcludedDeviceCount", system: func(nvml nvmlLibrary) error { _, err := nvml.ExcludedDeviceCount(); return err }},
+	{name: "UnitCount", system: func(nvml nvmlLibrary) error { _, err := nvml.UnitCount(); return err }},
+	{name: "DeviceCount", system: func(nvml nvmlLibrary) error { _, err := nvml.DeviceCount(); return err }},
+
+	{name: "MinorNumber", device: func(dev nvmlDevice) error { _, err := dev.MinorNumber(); return err }},
+	{name: "UUID", device: func(dev nvmlDevice) error { _, err := dev.UUID(); return err }},
+	{name: "Name", device: func(dev nvmlDevice) error { _, err := dev.Name(); return err }},
+
+	{name: "Serial", device: func(dev nvmlDevice) error { _, err := dev.Serial(); return err }},
+	{name: "VbiosVersion", device: func(dev nvmlDevice) error { _, err := dev.VbiosVersion(); return err }},
+	{name: "PCIInfo", device: func(dev nvmlDevice) error { _, err := dev.PCIInfo(); return err }},
+	{name: "BoardPartNumber", device: func(dev nvmlDevice) error { _, err := dev.BoardPartNumber(); return err }},
+	{name: "Brand", device: func(dev nvmlDevice) error { _, err := dev.Brand(); return err }},
+	{name: "Architecture", device: func(dev nvmlDevice) error { _, err := dev.Architecture(); return err }},
+	{name: "MultiGPUBoard", device: func(dev nvmlDevice) error { _, err := dev.MultiGPUBoard(); return err }},
+	{name: "BoardID", device: func(dev nvmlDevice) error { _, err := dev.BoardID(); return err }},
+	{name: "IrqNum", device: func(dev nvmlDevice) error { _, err := dev.IrqNum(); return err }},
+	{name: "NumaNodeID", device: func(dev nvmlDevice) error { _, err := dev.NumaNodeID(); return err }},
+	{name: "CudaComputeCapability", device: func(dev nvmlDevice) error { _, _, err := dev.CudaComputeCapability(); return err }},
+	{name: "Attributes", device: func(dev nvmlDevice) error { _, err := dev.Attributes(); return err }},
+	{name: "NumGpuCores", device: func(dev nvmlDevice) error { _, err := dev.NumGpuCores(); return err }},
+	{name: "InforomVersion", device: func(dev nvmlDevice) error { _, err := dev.InforomVersion(gonvml.InforomOEM); return err }},
+	{name: "InforomImageVersion", device: func(dev nvmlDevice) error { _, err := dev.InforomImageVersion(); return err }},
+	{name: "ValidateInforom", device: func(dev nvmlDevice) error { return dev.ValidateInforom() }},
+
+	{name: "MemoryInfo", probe: "memory", device: func(dev nvmlDevice) error { _, _, err := dev.MemoryInfo(); return err }},
+	{name: "MemoryInfoV2", device: func(dev nvmlDevice) error { _, err := dev.MemoryInfoV2(); return err }},
+	{name: "UtilizationRates", probe: "utilization", device: func(dev nvmlDevice) error { _, _, err := dev.UtilizationRates(); return err }},
+	{name: "AverageGPUUtilization", device: func(dev nvmlDevice) error { _, err := dev.AverageGPUUtilization(time.Second); return err }},
+	{name: "ProcessUtilization", device: func(dev nvmlDevice) error { _, err := dev.ProcessUtilization(0); return err }},
+	{name: "Samples", device: func(dev nvmlDevice) error {
+		_, err := dev.Samples(gonvml.SamplingTypeGPUUtilization, 0)
+		return err
+	}},
+	{name: "PowerUsage", probe: "power", device: func(dev nvmlDevice) error { _, err := dev.PowerUsage(); return err }},
+	{name: "AveragePowerUsage", device: func(dev nvmlDevice) error { _, err := dev.AveragePowerUsage(time.Second); return err }},
+	{name: "PowerManagementLimitConstraints", device: func(dev nvmlDevice) error { _, _, err := dev.PowerManagementLimitConstraints(); return err }},
+	{name: "PowerManagementDefaultLimit", device: func(dev nvmlDevice) error { _, err := dev.PowerManagementDefaultLimit(); return err }},
+	{name: "EnforcedPowerLimit", device: func(dev nvmlDevice) error { _, err := dev.EnforcedPowerLimit(); return err }},
+	{name: "PowerState", device: func(dev nvmlDevice) error { _, err := dev.PowerState(); return err }},
+	{name: "PowerSource", device: func(dev nvmlDevice) error { _, err := dev.PowerSource(); return err }},
+	{name: "Temperature", probe: "temperature", device: func(dev nvmlDevice) error { _, err := dev.Temperature(); return err }},
+	{name: "TemperatureThreshold", device: func(dev nvmlDevice) error {
+		_, err := dev.TemperatureThreshold(gonvml.TemperatureThresholdShutdown)
+		return err
+	}},
+	{name: "ThermalSettings", device: func(dev nvmlDevice) error { _, err := dev.ThermalSettings(); return err }},
+	{name: "MemoryTemperature", device: func(dev nvmlDevice) error { _, err := dev.MemoryTemperature(); return err }},
+	{name: "HotspotTemperature", device: func(dev nvmlDevice) error { _, err := dev.HotspotTemperature(); return err }},
+	{name: "FanSpeed", probe: "fan_speed", device: func(dev nvmlDevice) error { _, err := dev.FanSpeed(); return err }},
+	{name: "ViolationStatus", device: func(dev nvmlDevice) error {
+		_, _, err := dev.ViolationStatus(gonvml.PerfPolicyThermal)
+		return err
+	}},
+	{name: "EncoderStats", device: func(dev nvmlDevice) error { _, _, _, err := dev.EncoderStats(); return err }},
+	{name: "ApplicationsClock", device: func(dev nvmlDevice) error { _, err := dev.ApplicationsClock(gonvml.ClockGraphics); return err }},
+	{name: "DefaultApplicationsClock", device: func(dev nvmlDevice) error {
+		_, err := dev.DefaultApplicationsClock(gonvml.ClockGraphics)
+		return err
+	}},
+	{name: "MaxCustomerBoostClock", device: func(dev nvmlDevice) error { _, err := dev.MaxCustomerBoostClock(gonvml.ClockGraphics); return err }},
+	{name: "SupportedPerformanceStates", device: func(dev nvmlDevice) error { _, err := dev.SupportedPerformanceStates(); return err }},
+	{name: "ClockOffsets", device: func(dev nvmlDevice) error { _, err := dev.ClockOffsets(gonvml.ClockGraphics, 0); return err }},
+
+	{name: "EccMode", probe: "ecc", device: func(dev nvmlDevice) error { _, _, err := dev.EccMode(); return err }},
+	{name: "RemappedRows", device: func(dev nvmlDevice) error { _, _, _, _, err := dev.RemappedRows(); return err }},
+	{name: "FieldValues", device: func(dev nvmlDevice) error {
+		_, err := dev.FieldValues([]uint{gonvml.FieldDevGetGpuRecoveryAction})
+		return err
+	}},
+	{name: "GpuRecoveryAction", device: func(dev nvmlDevice) error {
+		values, err := dev.FieldValues([]uint{gonvml.FieldDevGetGpuRecoveryAction})
+		if err == nil && len(values) == 1 {
+			err = values[0].Err
+		}
+		return err
+	}},
+	{name: "GpuOperationMode", device: func(dev nvmlDevice) error { _, _, err := dev.GpuOperationMode(); return err }},
+	{name: "DriverModel", device: func(dev nvmlDevice) error { _, _, err := dev.DriverModel(); return err }},
+	{name: "GspFirmwareMode", device: func(dev nvmlDevice) error { _, _, err := dev.GspFirmwareMode(); return err }},
+	{name: "DisplayActive", device: func(dev nvmlDevice) error { _, err := dev.DisplayActive(); return err }},
+	{name: "DisplayMode", device: func(dev nvmlDevice) error { _, err := dev.DisplayMode(); return err }},
+	{name: "VirtualizationMode", device: func(dev nvmlDevice) error { _, err := dev.VirtualizationMode(); return err }},
+	{name: "HostVgpuMode", device: func(dev nvmlDevice) error { _, err := dev.HostVgpuMode(); return err }},
+
+	{name: "NvLinkState", device: func(dev nvmlDevice) error { _, err := dev.NvLinkState(0); return err }},
+	{name: "NvLinkThroughput", device: func(dev nvmlDevice) error { _, _, err := dev.NvLinkThroughput(0); return err }},
+	{name: "NvLinkErrorCounter", device: func(dev nvmlDevice) error {
+		_, err := dev.NvLinkErrorCounter(0, gonvml.NvLinkErrorDLReplay)
+		return err
+	}},
+	{name: "NvLinkRemotePCIInfo", device: func(dev nvmlDevice) error { _, err := dev.NvLinkRemotePCIInfo(0); return err }},
+	{name: "NvLinkRemoteDeviceType", device: func(dev nvmlDevice) error { _, err := dev.NvLinkRemoteDeviceType(0); return err }},
+	{name: "C2CLinkCount", device: func(dev nvmlDevice) error { _, err := dev.C2CLinkCount(); return err }},
+	{name: "C2CLinkStatus", device: func(dev nvmlDevice) error { _, err := dev.C2CLinkStatus(); return err }},
+	{name: "C2CLinkMaxBandwidth", device: func(dev nvmlDevice) error { _, err := dev.C2CLinkMaxBandwidth(0); return err }},
+	{name: "GpuFabricInfo", device: func(dev nvmlDevice) error { _, err := dev.GpuFabricInfo(); return err }},
+
+	{name: "ComputeRunningProcesses", device: func(dev nvmlDevice) error { _, err := dev.ComputeRunningProcesses(); return err }},
+	{name: "GraphicsRunningProcesses", device: func(dev nvmlDevice) error { _, err := dev.GraphicsRunningProcesses(); return err }},
+	{name: "AccountingMode", device: func(dev nvmlDevice) error { _, err := dev.AccountingMode(); return err }},
+	{name: "AccountingBufferSize", device: func(dev nvmlDevice) error { _, err := dev.AccountingBufferSize(); return err }},
+	{name: "AccountingPids", device: func(dev nvmlDevice) error { _, err := dev.AccountingPids(); return err }},
+
+	{name: "MigMode", device: func(dev nvmlDevice) error { _, _, err := dev.MigMode(); return err }},
+	{name: "MaxMigDeviceCount", device: func(dev nvmlDevice) error { _, err := dev.MaxMigDeviceCount(); return err }},
+	{name: "ActiveVgpus", device: func(dev nvmlDevice) error { _, err := dev.ActiveVgpus(); return err }},
+	{name: "GridLicensableFeatures", device: func(dev nvmlDevice) error { _, err := dev.GridLicensableFeatures(); return err }},
+
+	{name: "GpmQueryDeviceSupport", device: func(dev nvmlDevice) error { _, err := dev.GpmQueryDeviceSupport(); return err }},
+	{name: "SupportedEventTypes", device: func(dev nvmlDevice) error { _, err := dev.SupportedEventTypes(); return err }},
+}
+
+// selfTestQueries are the queries run by -selftest, i.e. all of them.
+var selfTestQueries = queries
+
+// probedQueries are the queries whose support is probed once per device and
+// exported as nvidia_gpu_query_supported, so that a missing series can be told
+// apart from a failing query.
+var probedQueries = func() []query {
+	var probed []query
+	for _, q := range queries {
+		if q.probe != "" {
+			probed = append(probed, q)
+		}
+	}
+	return probed
+}()
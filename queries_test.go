@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestQueriesComplete checks that every query whose failure is reported with
+// queryFailed is in queries, so that -selftest runs it.
+func TestQueriesComplete(t *testing.T) {
+	// Queries that need a handle returned by another query.
+	skipped := map[string]bool{
+		"GpmSampleAlloc": true,
+		"GpmSampleGet":   true,
+		"GpmMetricsGet":  true,
+	}
+
+	names := make(map[string]bool)
+	for _, q := range queries {
+		if names[q.name] {
+			t.Errorf("query %s is listed twice", q.name)
+		}
+		names[q.name] = true
+		if (q.system == nil) == (q.device == nil) {
+			t.Errorf("query %s must query either the system or a device", q.name)
+		}
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range pkgs["main"].Files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "queryFailed" || len(call.Args) != 3 {
+				return true
+			}
+			lit, ok := call.Args[2].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			name, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !names[name] && !skipped[name] {
+				t.Errorf("%s: query %s is missing from queries", fset.Position(lit.Pos()), name)
+			}
+			return true
+		})
+	}
+}
+
+func TestProbedQueries(t *testing.T) {
+	var probes []string
+	for _, q := range probedQueries {
+		if q.device == nil {
+			t.Errorf("probed query %s doesn't query a device", q.name)
+		}
+		probes = append(probes, q.probe)
+	}
+	if got, want := strings.Join(probes, ","), "memory,utilization,power,temperature,fan_speed,ecc"; got != want {
+		t.Errorf("probed queries = %s, want %s", got, want)
+	}
+
+	dev := &fakeDevice{uuid: "GPU-0"}
+	supported := probeQueries(dev, 0)
+	for _, probe := range []string{"memory", "utilization", "power"} {
+		if !supported[probe] {
+			t.Errorf("%s not supported", probe)
+		}
+	}
+	for _, probe := range []string{"temperature", "fan_speed", "ecc"} {
+		if supported[probe] {
+			t.Errorf("%s supported", probe)
+		}
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	lib := &fakeNVML{
+		driverVersion: "550.54.15",
+		devices:       []*fakeDevice{{uuid: "GPU-0", name: "Tesla T4"}},
+	}
+	var buf bytes.Buffer
+	if err := runSelfTest(&buf, lib); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(queries)+1 {
+		t.Fatalf("got %d lines, want a header and one per query:\n%s", len(lines), buf.String())
+	}
+	for _, want := range []string{
+		"SystemDriverVersion  ok",
+		"UUID                 ok",
+		"FanSpeed             not_supported (nvml: Not Supported)",
+	} {
+		found := false
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			if strings.Join(fields, " ") == strings.Join(strings.Fields(want), " ") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("self-test output lacks %q:\n%s", want, buf.String())
+		}
+	}
+
+	if err := runSelfTest(&buf, &fakeNVML{}); err == nil {
+		t.Error("self-test without devices succeeded")
+	}
+}
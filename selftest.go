@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// selfTest runs selfTestQueries against the system and the first device and
+// writes whether each succeeded to w. It returns an error if there is no device
+// to test.
+func selfTest(w io.Writer) error {
+	return runSelfTest(w, gonvmlLibrary{})
+}
+
+func runSelfTest(w io.Writer, nvml nvmlLibrary) error {
+	dev, err := nvml.DeviceHandleByIndex(0)
+	if err != nil {
+		return fmt.Errorf("cannot get the first device: %v", err)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "QUERY\tRESULT")
+	for _, q := range selfTestQueries {
+		if q.system != nil {
+			err = q.system(nvml)
+		} else {
+			err = q.device(dev)
+		}
+		result := "ok"
+		if err != nil {
+			result = nvmlErrorCode(err) + " (" + err.Error() + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", q.name, result)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xofym/gonvml"
+)
+
+// staticInfo holds the properties of a device that don't change while the
+// exporter is running. Each field is only valid if the corresponding ok field
+// is set, i.e. the query is supported by the device. String fields are empty
+// instead.
+type staticInfo struct {
+	serial          string
+	vbiosVersion    string
+	pciBusID        string
+	pciDeviceID     string
+	pciSubsystemID  string
+	boardPartNumber string
+	brand           string
+	architecture    string
+	irq             string
+
+	multiGPUBoard   bool
+	multiGPUBoardOK bool
+
+	boardID   uint
+	boardIDOK bool
+
+	computeCapabilityMajor int
+	computeCapabilityMinor int
+	computeCapabilityOK    bool
+
+	multiprocessors   uint
+	multiprocessorsOK bool
+
+	cudaCores   uint
+	cudaCoresOK bool
+
+	// -1 if the device has no NUMA affinity.
+	numaNode   int
+	numaNodeOK bool
+
+	nvlinkRemotes []nvlinkRemote
+
+	// 0 on systems without C2C links.
+	c2cLinks uint
+
+	// Only probed with -collector.gpm.
+	gpmSupported bool
+
+	// Set once vGPUs turned out to be unsupported by the device, see
+	// collectVgpus.
+	vgpuUnsupported bool
+
+	// Empty if the device has no InfoROM.
+	inforomOEMVersion   string
+	inforomECCVersion   string
+	inforomPowerVersion string
+	inforomImageVersion string
+
+	// Validating the InfoROM is slow, so it's only done when the device is
+	// first seen and on POST requests to /collect, see validateInforom.
+	inforomValidated bool
+	inforomValid     bool
+	inforomValidOK   bool
+
+	// In MHz by clock label value, only for the clocks the device reports
+	// one for.
+	maxCustomerBoostClocks map[string]uint
+
+	// Whether the device supports each of probedQueries.
+	supported map[string]bool
+}
+
+// nvlinkRemote describes what an NVLink link of a device is connected to.
+type nvlinkRemote struct {
+	link       string
+	pciBusID   string
+	deviceType string
+}
+
+// staticInfo returns the static properties of dev, querying them the first
+// time the device is seen. The caller must hold the lock.
+func (c *Collector) staticInfo(dev nvmlDevice, i int, uuid string) *staticInfo {
+	if info, ok := c.static[uuid]; ok {
+		return info
+	}
+
+	info := &staticInfo{}
+	var err error
+
+	info.serial, err = dev.Serial()
+	if err != nil {
+		c.queryFailed(err, i, "Serial")
+	}
+
+	info.vbiosVersion, err = dev.VbiosVersion()
+	if err != nil {
+		c.queryFailed(err, i, "VbiosVersion")
+	}
+
+	pciInfo, err := dev.PCIInfo()
+	if err != nil {
+		c.queryFailed(err, i, "PCIInfo")
+	} else {
+		info.pciBusID = pciInfo.BusID
+		info.pciDeviceID = pciID(pciInfo.DeviceID)
+		info.pciSubsystemID = pciID(pciInfo.SubsystemID)
+	}
+
+	info.boardPartNumber, err = dev.BoardPartNumber()
+	if err != nil {
+		c.queryFailed(err, i, "BoardPartNumber")
+	}
+
+	brand, err := dev.Brand()
+	if err != nil {
+		c.queryFailed(err, i, "Brand")
+	} else {
+		info.brand = brandName(brand)
+	}
+
+	architecture, err := dev.Architecture()
+	if err != nil {
+		c.queryFailed(err, i, "Architecture")
+	} else {
+		info.architecture = architectureName(architecture)
+	}
+
+	irq, err := dev.IrqNum()
+	if err != nil {
+		c.queryFailed(err, i, "IrqNum")
+	} else {
+		info.irq = strconv.FormatUint(uint64(irq), 10)
+	}
+
+	info.multiGPUBoard, err = dev.MultiGPUBoard()
+	if err != nil {
+		c.queryFailed(err, i, "MultiGPUBoard")
+	} else {
+		info.multiGPUBoardOK = true
+	}
+
+	info.boardID, err = dev.BoardID()
+	if err != nil {
+		c.queryFailed(err, i, "BoardID")
+	} else {
+		info.boardIDOK = true
+	}
+
+	info.computeCapabilityMajor, info.computeCapabilityMinor, err = dev.CudaComputeCapability()
+	if err != nil {
+		c.queryFailed(err, i, "CudaComputeCapability")
+	} else {
+		info.computeCapabilityOK = true
+	}
+
+	attributes, err := dev.Attributes()
+	if err != nil {
+		c.queryFailed(err, i, "Attributes")
+	} else {
+		info.multiprocessors = attributes.MultiprocessorCount
+		info.multiprocessorsOK = true
+	}
+
+	info.cudaCores, err = dev.NumGpuCores()
+	if err != nil {
+		c.queryFailed(err, i, "NumGpuCores")
+	} else {
+		info.cudaCoresOK = true
+	}
+
+	info.numaNode, info.numaNodeOK = c.deviceNumaNode(dev, i, info.pciBusID)
+
+	info.supported = probeQueries(dev, i)
+
+	info.maxCustomerBoostClocks = make(map[string]uint)
+	for _, clock := range boostClocks {
+		mhz, err := dev.MaxCustomerBoostClock(clock.clockType)
+		if err != nil {
+			c.queryFailed(err, i, "MaxCustomerBoostClock")
+			continue
+		}
+		info.maxCustomerBoostClocks[clock.clock] = mhz
+	}
+
+	info.inforomOEMVersion, err = dev.InforomVersion(gonvml.InforomOEM)
+	if err != nil {
+		c.queryFailed(err, i, "InforomVersion")
+	}
+	info.inforomECCVersion, err = dev.InforomVersion(gonvml.InforomECC)
+	if err != nil {
+		c.queryFailed(err, i, "InforomVersion")
+	}
+	info.inforomPowerVersion, err = dev.InforomVersion(gonvml.InforomPower)
+	if err != nil {
+		c.queryFailed(err, i, "InforomVersion")
+	}
+	info.inforomImageVersion, err = dev.InforomImageVersion()
+	if err != nil {
+		c.queryFailed(err, i, "InforomImageVersion")
+	}
+
+	info.nvlinkRemotes = c.nvlinkRemotes(dev, i)
+
+	info.c2cLinks = c.c2cLinkCount(dev, i)
+
+	if *collectGpm {
+		info.gpmSupported, err = dev.GpmQueryDeviceSupport()
+		if err != nil {
+			c.queryFailed(err, i, "GpmQueryDeviceSupport")
+		} else if !info.gpmSupported {
+			log.Info().
+				Int("device_index", i).
+				Msg("GPM is not supported by the device, not collecting GPM metrics")
+		}
+	}
+
+	c.static[uuid] = info
+	return info
+}
+
+// nvmlNoNumaNode is the node NVML reports for devices without NUMA affinity,
+// i.e. -1 as an unsigned int.
+const nvmlNoNumaNode = math.MaxUint32
+
+// sysfsPCIDevices is the sysfs directory of the PCI devices.
+var sysfsPCIDevices = "/sys/bus/pci/devices"
+
+// c2cLinkCount returns the number of C2C links of dev. Only Grace Hopper and
+// later systems have them; once a device doesn't support the query, the
+// other devices are assumed not to either and aren't queried. The caller must
+// hold the lock.
+func (c *Collector) c2cLinkCount(dev nvmlDevice, i int) uint {
+	if c.c2cUnsupported {
+		return 0
+	}
+
+	links, err := dev.C2CLinkCount()
+	if isNVMLError(err, gonvml.ErrorFunctionNotFound) || isNVMLError(err, gonvml.ErrorNotSupported) {
+		log.Info().
+			Err(err).
+			Msg("C2C links are not supported, not collecting C2C metrics")
+		c.c2cUnsupported = true
+		return 0
+	}
+	if err != nil {
+		c.queryFailed(err, i, "C2CLinkCount")
+		return 0
+	}
+	return links
+}
+
+// validateInforom validates the checksum of the InfoROM of dev, unless it has
+// been validated before. The caller must hold the lock.
+func (c *Collector) validateInforom(dev nvmlDevice, i int, info *staticInfo) {
+	if info.inforomValidated {
+		return
+	}
+	info.inforomValidated = true
+
+	err := dev.ValidateInforom()
+	switch {
+	case err == nil:
+		info.inforomValid, info.inforomValidOK = true, true
+	case isNVMLError(err, gonvml.ErrorCorruptedInforom):
+		log.Warn().
+			Int("device_index", i).
+			Msg("InfoROM is corrupted")
+		info.inforomValid, info.inforomValidOK = false, true
+	default:
+		c.queryFailed(err, i, "ValidateInforom")
+		info.inforomValidOK = false
+	}
+}
+
+// revalidateInforoms makes the next update validate the InfoROM of every device
+// again.
+func (c *Collector) revalidateInforoms() {
+	c.Lock()
+	defer c.Unlock()
+
+	for _, info := range c.static {
+		info.inforomValidated = false
+	}
+}
+
+// probeQueries returns which of probedQueries dev supports. Only failures with
+// NVML_ERROR_NOT_SUPPORTED mean that a query is unsupported, other errors may
+// be transient.
+func probeQueries(dev nvmlDevice, i int) map[string]bool {
+	supported := make(map[string]bool)
+	for _, q := range probedQueries {
+		err := q.device(dev)
+		supported[q.probe] = !isNVMLError(err, gonvml.ErrorNotSupported)
+		if !supported[q.probe] {
+			log.Debug().
+				Int("device_index", i).
+				Str("query", q.probe).
+				Msg("Query is not supported by the device")
+		}
+	}
+	return supported
+}
+
+// deviceNumaNode returns the NUMA node the device is attached to, -1 if it has
+// no NUMA affinity. Older drivers don't support the query, and some drivers
+// don't support it for devices without NUMA affinity, so the node is read
+// from sysfs instead.
+func (c *Collector) deviceNumaNode(dev nvmlDevice, i int, pciBusID string) (int, bool) {
+	node, err := dev.NumaNodeID()
+	if err == nil {
+		if node == nvmlNoNumaNode {
+			return -1, true
+		}
+		return int(node), true
+	}
+	c.queryFailed(err, i, "NumaNodeID")
+
+	if sysfsNode, ok := sysfsNumaNode(i, pciBusID); ok {
+		return sysfsNode, true
+	}
+	if isNVMLError(err, gonvml.ErrorNotSupported) {
+		return -1, true
+	}
+	return 0, false
+}
+
+// sysfsNumaNode reads the NUMA node of the device with the given PCI bus ID
+// from sysfs.
+func sysfsNumaNode(i int, pciBusID string) (int, bool) {
+	if pciBusID == "" {
+		return 0, false
+	}
+	path := filepath.Join(sysfsPCIDevices, sysfsBusID(pciBusID), "numa_node")
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Debug().Err(err).Int("device_index", i).Msg("Cannot read NUMA node from sysfs")
+		return 0, false
+	}
+	node, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		log.Debug().Err(err).Int("device_index", i).Msg("Cannot parse NUMA node from sysfs")
+		return 0, false
+	}
+	// The kernel reports -1 for devices without NUMA affinity, e.g. on
+	// single socket systems.
+	if node < 0 {
+		return -1, true
+	}
+	return node, true
+}
+
+// sysfsBusID converts a PCI bus ID as reported by NVML, e.g.
+// "00000000:3B:00.0", to the form used by sysfs, e.g. "0000:3b:00.0".
+func sysfsBusID(busID string) string {
+	busID = strings.ToLower(busID)
+	if parts := strings.SplitN(busID, ":", 2); len(parts) == 2 && len(parts[0]) > 4 {
+		busID = parts[0][len(parts[0])-4:] + ":" + parts[1]
+	}
+	return busID
+}
+
+// pciID formats a combined PCI device and vendor ID, e.g. 0x20b010de, the way
+// nvidia-smi does, but in lowercase.
+func pciID(id uint32) string {
+	return fmt.Sprintf("0x%08x", id)
+}
+
+// brandNames maps nvmlBrandType_t values to label values.
+var brandNames = map[uint]string{
+	1:  "quadro",
+	2:  "tesla",
+	3:  "nvs",
+	4:  "grid",
+	5:  "geforce",
+	6:  "titan",
+	7:  "nvidia_vapps",
+	8:  "nvidia_vpc",
+	9:  "nvidia_vcs",
+	10: "nvidia_vws",
+	11: "nvidia_cloud_gaming",
+	12: "quadro_rtx",
+	13: "nvidia_rtx",
+	14: "nvidia",
+	15: "geforce_rtx",
+	16: "titan_rtx",
+}
+
+// brandName returns the label value for an nvmlBrandType_t. Values that are
+// unknown to the exporter, e.g. ones added by newer drivers, are reported as
+// "unknown".
+func brandName(brand uint) string {
+	if name, ok := brandNames[brand]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// architectureNames maps nvmlDeviceArchitecture_t values to label values.
+var architectureNames = map[uint]string{
+	2:  "kepler",
+	3:  "maxwell",
+	4:  "pascal",
+	5:  "volta",
+	6:  "turing",
+	7:  "ampere",
+	8:  "ada",
+	9:  "hopper",
+	10: "blackwell",
+}
+
+// architectureName returns the label value for an nvmlDeviceArchitecture_t.
+// Values that are unknown to the exporter are reported as "unknown_" followed
+// by the value, so that new architectures can still be told apart.
+func architectureName(architecture uint) string {
+	if name, ok := architectureNames[architecture]; ok {
+		return name
+	}
+	return "unknown_" + strconv.FormatUint(uint64(architecture), 10)
+}
+
+// nvlinkRemotes returns what the NVLink links of dev are connected to. Links
+// that are not connected are left out.
+func (c *Collector) nvlinkRemotes(dev nvmlDevice, i int) []nvlinkRemote {
+	var remotes []nvlinkRemote
+	for link := uint(0); link < gonvml.NvLinkMaxLinks; link++ {
+		pciInfo, err := dev.NvLinkRemotePCIInfo(link)
+		// See collectNvLinks.
+		if isNVMLError(err, gonvml.ErrorInvalidArgument) || isNVMLError(err, gonvml.ErrorNotSupported) {
+			break
+		}
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Uint("link", link).
+				Msg("Cannot get NvLinkRemotePCIInfo")
+			c.countError(err)
+			continue
+		}
+
+		deviceType, err := dev.NvLinkRemoteDeviceType(link)
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Uint("link", link).
+				Msg("Cannot get NvLinkRemoteDeviceType")
+			c.countError(err)
+			continue
+		}
+
+		remotes = append(remotes, nvlinkRemote{
+			link:       strconv.FormatUint(uint64(link), 10),
+			pciBusID:   pciInfo.BusID,
+			deviceType: nvlinkDeviceTypeName(deviceType),
+		})
+	}
+	return remotes
+}
+
+// nvlinkDeviceTypeName returns the label value for an nvmlIntNvLinkDeviceType_t.
+func nvlinkDeviceTypeName(deviceType uint) string {
+	switch deviceType {
+	case 0:
+		return "gpu"
+	case 1:
+		return "cpu"
+	case 2:
+		return "nvswitch"
+	}
+	return "unknown"
+}
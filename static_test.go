@@ -0,0 +1,160 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPCIID(t *testing.T) {
+	tests := []struct {
+		id   uint32
+		want string
+	}{
+		{0x20b010de, "0x20b010de"},
+		{0x1eb810DE, "0x1eb810de"},
+		{0x145f10de, "0x145f10de"},
+		{0x000010de, "0x000010de"},
+		{0, "0x00000000"},
+		{0xffffffff, "0xffffffff"},
+	}
+
+	for _, tt := range tests {
+		if got := pciID(tt.id); got != tt.want {
+			t.Errorf("pciID(%#x) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestBrandName(t *testing.T) {
+	tests := []struct {
+		brand uint
+		want  string
+	}{
+		{0, "unknown"},
+		{1, "quadro"},
+		{2, "tesla"},
+		{5, "geforce"},
+		{14, "nvidia"},
+		{16, "titan_rtx"},
+		{17, "unknown"},
+		{1000, "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := brandName(tt.brand); got != tt.want {
+			t.Errorf("brandName(%d) = %q, want %q", tt.brand, got, tt.want)
+		}
+	}
+
+	// Every brand has its own label value.
+	seen := make(map[string]uint)
+	for brand, name := range brandNames {
+		if other, ok := seen[name]; ok {
+			t.Errorf("brands %d and %d both map to %q", brand, other, name)
+		}
+		seen[name] = brand
+		if name == "unknown" {
+			t.Errorf("brand %d maps to %q", brand, name)
+		}
+	}
+}
+
+func TestArchitectureName(t *testing.T) {
+	tests := []struct {
+		architecture uint
+		want         string
+	}{
+		{2, "kepler"},
+		{7, "ampere"},
+		{9, "hopper"},
+		{10, "blackwell"},
+		// Unknown values stay distinguishable.
+		{0, "unknown_0"},
+		{11, "unknown_11"},
+		{0xffffffff, "unknown_4294967295"},
+	}
+
+	for _, tt := range tests {
+		if got := architectureName(tt.architecture); got != tt.want {
+			t.Errorf("architectureName(%d) = %q, want %q", tt.architecture, got, tt.want)
+		}
+	}
+}
+
+// numaDevice returns node and err from NumaNodeID.
+type numaDevice struct {
+	fakeDevice
+	node uint
+	err  error
+}
+
+func (d *numaDevice) NumaNodeID() (uint, error) { return d.node, d.err }
+
+func TestDeviceNumaNode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(path string) { sysfsPCIDevices = path }(sysfsPCIDevices)
+	sysfsPCIDevices = dir
+
+	for busID, node := range map[string]string{"0000:3b:00.0": "1\n", "0000:5e:00.0": "-1\n"} {
+		if err := os.MkdirAll(filepath.Join(dir, busID), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, busID, "numa_node"), []byte(node), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		node     uint
+		err      error
+		pciBusID string
+		want     int
+		wantOK   bool
+	}{
+		{"node", 1, nil, "00000000:3B:00.0", 1, true},
+		{"no affinity", math.MaxUint32, nil, "00000000:3B:00.0", -1, true},
+		{"not supported, sysfs node", 0, errNotSupported, "00000000:3B:00.0", 1, true},
+		{"not supported, no sysfs node", 0, errNotSupported, "00000000:AF:00.0", -1, true},
+		{"not supported, no bus ID", 0, errNotSupported, "", -1, true},
+		{"old driver, sysfs node", 0, errFunctionNotFound, "00000000:3B:00.0", 1, true},
+		{"old driver, sysfs without affinity", 0, errFunctionNotFound, "00000000:5E:00.0", -1, true},
+		{"old driver, no sysfs node", 0, errFunctionNotFound, "00000000:AF:00.0", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dev := &numaDevice{node: tt.node, err: tt.err}
+			c := NewCollector()
+			got, ok := c.deviceNumaNode(dev, 0, tt.pciBusID)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("deviceNumaNode() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestC2CLinkCountUnsupported(t *testing.T) {
+	devices := []*fakeDevice{
+		{uuid: "GPU-0", name: "Tesla T4"},
+		{minor: 1, uuid: "GPU-1", name: "Tesla T4"},
+	}
+	c := NewCollector()
+	c.nvml = &fakeNVML{devices: devices}
+	c.update()
+
+	// The other devices aren't queried once the first doesn't support C2C.
+	if devices[0].c2cLinkCountCalls != 1 || devices[1].c2cLinkCountCalls != 0 {
+		t.Errorf("C2CLinkCount called %d and %d times, want once for the first device", devices[0].c2cLinkCountCalls, devices[1].c2cLinkCountCalls)
+	}
+	if got := len(collectSeries(c.c2cLinks)); got != 0 {
+		t.Errorf("got %d c2c_links series, want 0", got)
+	}
+}
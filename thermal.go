@@ -0,0 +1,71 @@
+package main
+
+import "strconv"
+
+// thermalTargetNames maps nvmlThermalTarget_t values to label values.
+var thermalTargetNames = map[uint]string{
+	0:  "none",
+	1:  "gpu",
+	2:  "memory",
+	4:  "power_supply",
+	8:  "board",
+	9:  "vcd_board",
+	10: "vcd_inlet",
+	11: "vcd_outlet",
+}
+
+func thermalTargetName(target uint) string {
+	if name, ok := thermalTargetNames[target]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// thermalControllerNames maps nvmlThermalController_t values to label values.
+var thermalControllerNames = map[int]string{
+	0:  "none",
+	1:  "gpu_internal",
+	2:  "adm1032",
+	3:  "adt7461",
+	4:  "max6649",
+	5:  "max1617",
+	6:  "lm99",
+	7:  "lm89",
+	8:  "lm64",
+	9:  "g781",
+	10: "adt7473",
+	11: "sbmax6649",
+	12: "vbiosevt",
+	13: "os",
+	14: "nvsyscon_canoas",
+	15: "nvsyscon_e551",
+	16: "max6649r",
+	17: "adt7473s",
+}
+
+func thermalControllerName(controller int) string {
+	if name, ok := thermalControllerNames[controller]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// collectThermalSettings reads the thermal sensors of the device with the
+// temperature they report and their default range. The caller must hold the
+// lock.
+func (c *Collector) collectThermalSettings(dev nvmlDevice, i int, minor, uuid, name string) {
+	settings, err := dev.ThermalSettings()
+	if err != nil {
+		c.queryFailed(err, i, "ThermalSettings")
+		return
+	}
+	// Only the first settings.Count sensors are set, the rest of the array is
+	// zeroed.
+	for index := 0; index < int(settings.Count) && index < len(settings.Sensors); index++ {
+		sensor := settings.Sensors[index]
+		values := []string{minor, uuid, name, strconv.Itoa(index), thermalTargetName(sensor.Target), thermalControllerName(sensor.Controller)}
+		c.thermalSensorTarget.WithLabelValues(values...).Set(float64(sensor.CurrentTemp))
+		c.thermalSensorDefaultMin.WithLabelValues(values...).Set(float64(sensor.DefaultMinTemp))
+		c.thermalSensorDefaultMax.WithLabelValues(values...).Set(float64(sensor.DefaultMaxTemp))
+	}
+}
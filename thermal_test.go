@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xofym/gonvml"
+)
+
+func TestCollectThermalSettings(t *testing.T) {
+	gpu := gonvml.ThermalSensor{Controller: 1, DefaultMinTemp: -30, DefaultMaxTemp: 95, CurrentTemp: 45, Target: 1}
+	memory := gonvml.ThermalSensor{Controller: 1, DefaultMinTemp: -30, DefaultMaxTemp: 105, CurrentTemp: 52, Target: 2}
+	board := gonvml.ThermalSensor{Controller: 13, DefaultMinTemp: 0, DefaultMaxTemp: 85, CurrentTemp: 38, Target: 8}
+
+	tests := []struct {
+		name     string
+		settings gonvml.ThermalSettings
+		want     []gonvml.ThermalSensor
+	}{
+		{
+			name: "no sensors",
+		},
+		{
+			name: "one sensor",
+			settings: gonvml.ThermalSettings{
+				Count:   1,
+				Sensors: [gonvml.ThermalMaxSensors]gonvml.ThermalSensor{gpu},
+			},
+			want: []gonvml.ThermalSensor{gpu},
+		},
+		{
+			name: "fewer sensors than the maximum",
+			settings: gonvml.ThermalSettings{
+				Count:   2,
+				Sensors: [gonvml.ThermalMaxSensors]gonvml.ThermalSensor{gpu, memory},
+			},
+			want: []gonvml.ThermalSensor{gpu, memory},
+		},
+		{
+			name: "maximum number of sensors",
+			settings: gonvml.ThermalSettings{
+				Count:   3,
+				Sensors: [gonvml.ThermalMaxSensors]gonvml.ThermalSensor{gpu, memory, board},
+			},
+			want: []gonvml.ThermalSensor{gpu, memory, board},
+		},
+		{
+			name: "count beyond the maximum",
+			settings: gonvml.ThermalSettings{
+				Count:   5,
+				Sensors: [gonvml.ThermalMaxSensors]gonvml.ThermalSensor{gpu, memory, board},
+			},
+			want: []gonvml.ThermalSensor{gpu, memory, board},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := tt.settings
+			dev := &fakeDevice{uuid: "GPU-0", name: "Tesla T4", thermal: &settings}
+			c := NewCollector()
+			c.collectThermalSettings(dev, 0, "0", "GPU-0", "Tesla T4")
+
+			series := collectSeries(c.thermalSensorTarget)
+			if len(series) != len(tt.want) {
+				t.Fatalf("got %d sensors, want %d", len(series), len(tt.want))
+			}
+			for _, m := range series {
+				labels := seriesLabels(m)
+				index, err := strconv.Atoi(labels["index"])
+				if err != nil || index < 0 || index >= len(tt.want) {
+					t.Fatalf("unexpected sensor index %q", labels["index"])
+				}
+				want := tt.want[index]
+				if got := m.GetGauge().GetValue(); got != float64(want.CurrentTemp) {
+					t.Errorf("sensor %d temperature = %v, want %v", index, got, want.CurrentTemp)
+				}
+				if got := labels["sensor"]; got != thermalTargetName(want.Target) {
+					t.Errorf("sensor %d sensor label = %q, want %q", index, got, thermalTargetName(want.Target))
+				}
+				if got := labels["controller"]; got != thermalControllerName(want.Controller) {
+					t.Errorf("sensor %d controller = %q, want %q", index, got, thermalControllerName(want.Controller))
+				}
+			}
+			for _, want := range []struct {
+				vec   prometheus.Collector
+				value func(gonvml.ThermalSensor) int
+			}{
+				{c.thermalSensorDefaultMin, func(s gonvml.ThermalSensor) int { return s.DefaultMinTemp }},
+				{c.thermalSensorDefaultMax, func(s gonvml.ThermalSensor) int { return s.DefaultMaxTemp }},
+			} {
+				var sum float64
+				for _, sensor := range tt.want {
+					sum += float64(want.value(sensor))
+				}
+				if got := sumSeries(want.vec); got != sum {
+					t.Errorf("sum of default temperatures = %v, want %v", got, sum)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xofym/gonvml"
+)
+
+// unitTemperatureSensors are the temperature sensors of units by the value of
+// the sensor label.
+var unitTemperatureSensors = []struct {
+	name   string
+	sensor gonvml.UnitTemperatureType
+}{
+	{"intake", gonvml.UnitTemperatureIntake},
+	{"exhaust", gonvml.UnitTemperatureExhaust},
+	{"board", gonvml.UnitTemperatureBoard},
+}
+
+// collectUnits reads the metrics of the S-class units of the system, e.g. the
+// PSUs, fans and temperatures of the chassis. The units are only counted on
+// the first call, systems without units don't query anything else. The caller
+// must hold the lock.
+func (c *Collector) collectUnits() {
+	if !c.unitCountOK {
+		count, err := c.nvml.UnitCount()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Msg("Cannot get UnitCount")
+			c.countError(err)
+			return
+		}
+		if count == 0 {
+			log.Info().
+				Msg("No units found, not collecting unit metrics")
+		}
+		c.unitCount = count
+		c.unitCountOK = true
+	}
+
+	for j := uint(0); j < c.unitCount; j++ {
+		unitIndex := strconv.FormatUint(uint64(j), 10)
+
+		unit, err := c.nvml.UnitHandleByIndex(j)
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Uint("unit_index", j).
+				Msg("Cannot get UnitHandleByIndex")
+			c.countError(err)
+			continue
+		}
+
+		info, err := unit.Info()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Uint("unit_index", j).
+				Msg("Cannot get unit Info")
+			c.countError(err)
+		} else {
+			c.unitInfo.WithLabelValues(unitIndex, info.Name, info.ID, info.Serial, info.FirmwareVersion).Set(1)
+		}
+
+		psu, err := unit.PsuInfo()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Uint("unit_index", j).
+				Msg("Cannot get unit PsuInfo")
+			c.countError(err)
+		} else {
+			c.unitPSUState.WithLabelValues(unitIndex, psu.State).Set(1)
+			c.unitPSUCurrent.WithLabelValues(unitIndex).Set(float64(psu.Current))
+			c.unitPSUVoltage.WithLabelValues(unitIndex).Set(float64(psu.Voltage))
+			c.unitPSUPower.WithLabelValues(unitIndex).Set(float64(psu.Power))
+		}
+
+		fans, err := unit.FanSpeedInfo()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Uint("unit_index", j).
+				Msg("Cannot get unit FanSpeedInfo")
+			c.countError(err)
+		} else {
+			for k, fan := range fans {
+				f := strconv.Itoa(k)
+				c.unitFanSpeed.WithLabelValues(unitIndex, f).Set(float64(fan.Speed))
+				c.unitFanFailed.WithLabelValues(unitIndex, f).Set(boolToFloat64(fan.Failed))
+			}
+		}
+
+		for _, s := range unitTemperatureSensors {
+			temperature, err := unit.Temperature(s.sensor)
+			if err != nil {
+				log.Debug().
+					Err(err).
+					Uint("unit_index", j).
+					Str("sensor", s.name).
+					Msg("Cannot get unit Temperature")
+				c.countError(err)
+				continue
+			}
+			c.unitTemperature.WithLabelValues(unitIndex, s.name).Set(float64(temperature))
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/xofym/gonvml"
+)
+
+// collectVgpus reads the metrics of the vGPU instances running on the device.
+// On hosts without the vGPU manager and on devices that don't support vGPUs,
+// the first query fails as not supported and the device isn't queried again.
+// The caller must hold the lock.
+func (c *Collector) collectVgpus(dev nvmlDevice, i int, info *staticInfo, minor, uuid, name string) {
+	if info.vgpuUnsupported {
+		return
+	}
+
+	instances, err := dev.ActiveVgpus()
+	if isNVMLError(err, gonvml.ErrorNotSupported) {
+		log.Info().
+			Int("device_index", i).
+			Msg("vGPUs are not supported by the device, not collecting vGPU metrics")
+		info.vgpuUnsupported = true
+		return
+	}
+	if err != nil {
+		c.queryFailed(err, i, "ActiveVgpus")
+		return
+	}
+	c.vgpuInstances.WithLabelValues(minor, uuid, name).Set(float64(len(instances)))
+
+	for _, instance := range instances {
+		id := strconv.FormatUint(uint64(instance), 10)
+
+		vmID, err := instance.VMID()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Str("vgpu_instance", id).
+				Msg("Cannot get vGPU VMID")
+			c.countError(err)
+			continue
+		}
+
+		fbUsage, err := instance.FbUsage()
+		if err != nil {
+			log.Debug().
+				Err(err).
+				Int("device_index", i).
+				Str("vgpu_instance", id).
+				Msg("Cannot get vGPU FbUsage")
+			c.countError(err)
+			continue
+		}
+		c.vgpuUsedMemory.WithLabelValues(minor, uuid, name, id, vmID).Set(memoryValue(float64(fbUsage)))
+	}
+}
+
+// collectLicenses reads the state of the vGPU licenses of the device. This is
+// only supported inside vGPU guests.
+func (c *Collector) collectLicenses(dev nvmlDevice, i int, minor, uuid, name string) {
+	features, err := dev.GridLicensableFeatures()
+	if err != nil {
+		c.queryFailed(err, i, "GridLicensableFeatures")
+		return
+	}
+
+	for _, feature := range features {
+		if !feature.Enabled {
+			continue
+		}
+		c.licenseStatus.WithLabelValues(minor, uuid, name, feature.ProductName).Set(boolToFloat64(feature.Licensed))
+		// Not every driver reports when the license expires.
+		if !feature.LicenseExpiry.IsZero() {
+			c.licenseExpiry.WithLabelValues(minor, uuid, name, feature.ProductName).Set(float64(feature.LicenseExpiry.Unix()))
+		}
+	}
+}